@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sunboyy/lettered/pkg/config"
+)
+
+var (
+	// errUsage is returned when a command is called with invalid
+	// arguments. The usage of the command is printed instead of the error.
+	errUsage = errors.New("invalid usage")
+
+	// errInvalidSetting is returned when the -set flag is not in the form
+	// key=value.
+	errInvalidSetting = errors.New("expected key=value")
+)
+
+// options contains the flags shared by all commands. They can be given either
+// before or after the command name.
+type options struct {
+	configPath string
+	dataDir    string
+	settings   settingsFlag
+}
+
+// settingsFlag collects the options set by the -set flags by key.
+type settingsFlag map[string]string
+
+// String implements the flag.Value interface.
+func (s settingsFlag) String() string {
+	return ""
+}
+
+// Set implements the flag.Value interface.
+func (s settingsFlag) Set(value string) error {
+	key, v, ok := strings.Cut(value, "=")
+	if !ok {
+		return errInvalidSetting
+	}
+	s[key] = v
+	return nil
+}
+
+// register adds the shared flags to the flag set.
+func (o *options) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.configPath, "config", o.configPath,
+		"path of the config file")
+	flags.StringVar(&o.dataDir, "data-dir", o.dataDir,
+		"directory of the database and the TLS identity, overriding "+
+			"AppDataDir")
+	flags.Var(o.settings, "set",
+		"set an option, e.g. Management.Port=11926 (repeatable)")
+}
+
+// parse parses the arguments of a command with the shared flags and checks
+// that the expected number of positional arguments remains.
+func (o *options) parse(flags *flag.FlagSet, args []string, nArgs int) error {
+	o.register(flags)
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil || flags.NArg() != nArgs {
+		return errUsage
+	}
+	return nil
+}
+
+// load merges the defaults, the config file, the environment variables and
+// the flags.
+func (o *options) load() (*config.Loaded, error) {
+	flags := make(map[string]string, len(o.settings)+1)
+	for key, value := range o.settings {
+		flags[key] = value
+	}
+	if o.dataDir != "" {
+		flags["AppDataDir"] = o.dataDir
+	}
+
+	loaded, err := config.Load(config.LoadOptions{
+		Path:  o.configPath,
+		Env:   os.Environ(),
+		Flags: flags,
+	})
+	if err != nil {
+		if errors.Is(err, config.ErrConfigNotFound) {
+			return nil, fmt.Errorf("%w (run lettered init)", err)
+		}
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	return loaded, nil
+}
+
+// loadConfig returns the merged configuration.
+func (o *options) loadConfig() (config.Config, error) {
+	loaded, err := o.load()
+	if err != nil {
+		return config.Config{}, err
+	}
+	return loaded.Config, nil
+}
+
+// command is a subcommand of lettered. The name may consist of several words,
+// e.g. "identity show".
+type command struct {
+	name        string
+	args        string
+	description string
+	run         func(opts *options, args []string) error
+}
+
+// synopsis returns the name of the command followed by its arguments.
+func (c *command) synopsis() string {
+	return strings.TrimSpace(c.name + " " + c.args)
+}
+
+var commands = []command{
+	{"init", "[-force]",
+		"write a default config file and generate the node identity",
+		runInit},
+	{"run", "", "run the daemon", runDaemon},
+	{"identity show", "", "print the node ID and the identifier",
+		runIdentityShow},
+	{"config check", "", "check that the config can be loaded",
+		runConfigCheck},
+	{"config show", "",
+		"print the effective options and where they come from",
+		runConfigShow},
+	{"passwd", "", "set the password of the management API", runPasswd},
+	{"version", "", "print the version", runVersion},
+}
+
+// findCommand returns the command with the longest name matching the
+// beginning of the arguments, and the remaining arguments.
+func findCommand(args []string) (*command, []string) {
+	var found *command
+	var foundWords int
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(words) <= foundWords || len(words) > len(args) {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == commands[i].name {
+			found = &commands[i]
+			foundWords = len(words)
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	return found, args[foundWords:]
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: lettered [-config <path>] [-data-dir <dir>] "+
+		"[-set <key>=<value>]... <command> [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\n", cmd.synopsis())
+		fmt.Fprintf(w, "\t%s\n", cmd.description)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Without a command, the daemon is run.")
+}
+
+func runDaemon(opts *options, args []string) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	if err := opts.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	loaded, err := opts.load()
+	if err != nil {
+		return err
+	}
+	if err := validateConfig(loaded); err != nil {
+		return err
+	}
+	if err := ensureAppDataDir(loaded.Config); err != nil {
+		return err
+	}
+
+	start(opts, loaded.Config)
+	return nil
+}
+
+func runPasswd(opts *options, args []string) error {
+	flags := flag.NewFlagSet("passwd", flag.ContinueOnError)
+	if err := opts.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := ensureAppDataDir(cfg); err != nil {
+		return err
+	}
+
+	return passwd(cfg)
+}
+
+func ensureAppDataDir(cfg config.Config) error {
+	if err := os.MkdirAll(cfg.AppDataDir, 0700); err != nil {
+		return fmt.Errorf("create app data directory: %w", err)
+	}
+	return nil
+}
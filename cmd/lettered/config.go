@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sunboyy/lettered/pkg/config"
+)
+
+// errInvalidConfig is returned when the configuration has problems.
+var errInvalidConfig = errors.New("invalid config")
+
+func runConfigCheck(opts *options, args []string) error {
+	flags := flag.NewFlagSet("config check", flag.ContinueOnError)
+	if err := opts.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	loaded, err := opts.load()
+	if err != nil {
+		return err
+	}
+	if err := validateConfig(loaded); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: ok\n", configFileName(loaded))
+	return nil
+}
+
+func runConfigShow(opts *options, args []string) error {
+	flags := flag.NewFlagSet("config show", flag.ContinueOnError)
+	if err := opts.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	loaded, err := opts.load()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("config file: %s\n\n", configFileName(loaded))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE\tENV")
+	for _, key := range config.Keys() {
+		value := config.Value(loaded.Config, key)
+		if config.IsSecret(key) && value != "" {
+			value = "********"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", key, value,
+			loaded.Sources[key], config.EnvName(key))
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("print options: %w", err)
+	}
+	return nil
+}
+
+// validateConfig validates the configuration. The error lists each problem on
+// its own line together with where the invalid value comes from.
+func validateConfig(loaded *config.Loaded) error {
+	err := loaded.Validate()
+	var validationErr *config.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	var b strings.Builder
+	for _, p := range validationErr.Problems {
+		fmt.Fprintf(&b, "\n  %s: %s (%s)", p.Key, p.Message,
+			sourceName(loaded, p.Key))
+	}
+	return fmt.Errorf("%w:%s", errInvalidConfig, b.String())
+}
+
+// sourceName describes where the value of the option comes from.
+func sourceName(loaded *config.Loaded, key string) string {
+	switch loaded.Sources[key] {
+	case config.SourceFile:
+		return "set in " + loaded.Path
+	case config.SourceEnv:
+		return "set by " + config.EnvName(key)
+	case config.SourceFlag:
+		return "set by flag"
+	default:
+		return "default"
+	}
+}
+
+func configFileName(loaded *config.Loaded) string {
+	if loaded.Path == "" {
+		return "(none, using defaults)"
+	}
+	return loaded.Path
+}
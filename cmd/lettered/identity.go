@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/sunboyy/lettered/pkg/config"
+	"github.com/sunboyy/lettered/pkg/p2p"
+	"github.com/sunboyy/lettered/pkg/tlsutil"
+)
+
+// errNoIdentity is returned when the TLS identity of the node has not been
+// generated.
+var errNoIdentity = errors.New("node identity not found (run lettered init)")
+
+func runInit(opts *options, args []string) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	force := flags.Bool("force", false,
+		"overwrite the config file if it exists")
+	if err := opts.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	if err := writeDefaultConfig(opts, *force); err != nil {
+		return err
+	}
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := ensureAppDataDir(cfg); err != nil {
+		return err
+	}
+
+	cert, err := tlsutil.LoadOrGenerateCertificate(certFile(cfg),
+		keyFile(cfg))
+	if err != nil {
+		return fmt.Errorf("load tls certificate: %w", err)
+	}
+	fmt.Printf("identity: %s\n", certFile(cfg))
+
+	return printIdentity(cfg, cert)
+}
+
+// writeDefaultConfig writes a default config file unless it exists and force
+// is false. Without the -config flag, the file is written to the path that
+// the config is loaded from, so that an existing file found in the search
+// paths is kept. The path of the file is set to the options.
+func writeDefaultConfig(opts *options, force bool) error {
+	content, err := config.DefaultFile(opts.dataDir)
+	if err != nil {
+		return fmt.Errorf("default config: %w", err)
+	}
+
+	if opts.configPath == "" {
+		opts.configPath = os.Getenv(config.EnvConfig)
+	}
+	if opts.configPath == "" {
+		opts.configPath = config.FindFile()
+	}
+	if opts.configPath == "" {
+		opts.configPath = config.DefaultPath()
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.configPath), 0700); err != nil {
+		return fmt.Errorf("create config directory: %w", err)
+	}
+
+	mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		mode |= os.O_EXCL
+	}
+
+	// The config file may contain the management password.
+	f, err := os.OpenFile(opts.configPath, mode, 0600)
+	if errors.Is(err, fs.ErrExist) {
+		fmt.Printf("config: %s exists, keeping it\n", opts.configPath)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("create config file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(content); err != nil {
+		return fmt.Errorf("write config file: %w", err)
+	}
+	fmt.Printf("config: %s written\n", opts.configPath)
+	return nil
+}
+
+func runIdentityShow(opts *options, args []string) error {
+	flags := flag.NewFlagSet("identity show", flag.ContinueOnError)
+	if err := opts.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	cfg, err := opts.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// The identity is only loaded since generating it here would change
+	// the identity of a node whose app data directory is misconfigured.
+	cert, err := tls.LoadX509KeyPair(certFile(cfg), keyFile(cfg))
+	if errors.Is(err, fs.ErrNotExist) {
+		return errNoIdentity
+	}
+	if err != nil {
+		return fmt.Errorf("load tls certificate: %w", err)
+	}
+
+	return printIdentity(cfg, cert)
+}
+
+// printIdentity prints the node ID derived from the certificate and the
+// identifier for other people to connect.
+func printIdentity(cfg config.Config, cert tls.Certificate) error {
+	nodeID, err := p2p.NodeIDFromCert(cert)
+	if err != nil {
+		return fmt.Errorf("derive node id: %w", err)
+	}
+
+	fmt.Printf("node id: %s\n", nodeID)
+	if cfg.Common.Hostname == "" {
+		fmt.Println("identifier: unavailable until Common.Hostname " +
+			"is set")
+		return nil
+	}
+	fmt.Printf("identifier: %s\n",
+		p2p.CreateIdentifier(nodeID, cfg.Common.Hostname))
+	return nil
+}
+
+func certFile(cfg config.Config) string {
+	return filepath.Join(cfg.AppDataDir, "tls.cert")
+}
+
+func keyFile(cfg config.Config) string {
+	return filepath.Join(cfg.AppDataDir, "tls.key")
+}
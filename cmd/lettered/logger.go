@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedQueryParams are the query parameters whose values are not written to
+// the request log because they carry credentials.
+var redactedQueryParams = []string{"access_token"}
+
+// requestLogFormatter formats the request log in the same way as the default
+// logger of gin, except that the credentials in the query are redacted.
+func requestLogFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+
+	if param.Latency > time.Minute {
+		param.Latency -= param.Latency % time.Second
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v"+
+		"\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		redactQuery(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// redactQuery replaces the values of the redacted query parameters in the
+// path. The query is dropped entirely if it cannot be parsed.
+func redactQuery(path string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 {
+		return path
+	}
+
+	query, err := url.ParseQuery(path[i+1:])
+	if err != nil {
+		return path[:i]
+	}
+	redacted := false
+	for _, key := range redactedQueryParams {
+		if _, ok := query[key]; ok {
+			query.Set(key, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return path
+	}
+	return path[:i+1] + query.Encode()
+}
@@ -1,31 +1,81 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 	"github.com/sunboyy/lettered/pkg/config"
 	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/event"
 	"github.com/sunboyy/lettered/pkg/friend"
+	"github.com/sunboyy/lettered/pkg/letter"
 	"github.com/sunboyy/lettered/pkg/management"
+	"github.com/sunboyy/lettered/pkg/outbox"
 	"github.com/sunboyy/lettered/pkg/p2p"
 	"github.com/sunboyy/lettered/pkg/tlsutil"
+	"github.com/sunboyy/lettered/pkg/webhook"
 )
 
+// shutdownTimeout is the maximum duration for waiting for in-flight requests
+// to complete when the daemon is shutting down.
+const shutdownTimeout = 15 * time.Second
+
 func main() {
-	cfg := config.LoadConfig()
+	opts := &options{settings: settingsFlag{}}
+
+	flags := flag.NewFlagSet("lettered", flag.ContinueOnError)
+	flags.Usage = func() { usage(flags.Output()) }
+	opts.register(flags)
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
 
-	start(cfg)
+	// The daemon is run without a command for compatibility.
+	args := flags.Args()
+	if len(args) == 0 {
+		args = []string{"run"}
+	}
+
+	cmd, args := findCommand(args)
+	if cmd == nil {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	if err := cmd.run(opts, args); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, "usage: lettered",
+				cmd.synopsis())
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "lettered %s: %v\n", cmd.name, err)
+		os.Exit(1)
+	}
 }
 
-func start(cfg config.Config) {
-	cert, err := tlsutil.LoadOrGenerateCertificate(
-		filepath.Join(cfg.AppDataDir, "tls.cert"),
-		filepath.Join(cfg.AppDataDir, "tls.key"),
-	)
+// start runs the daemon until it receives SIGINT or SIGTERM, and then shuts
+// it down gracefully. The config is reloaded from the options on SIGHUP.
+func start(opts *options, cfg config.Config) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt,
+		syscall.SIGTERM)
+	defer stop()
+
+	setLogLevel(cfg.LogLevel)
+
+	cert, err := tlsutil.LoadOrGenerateCertificate(certFile(cfg),
+		keyFile(cfg))
 	if err != nil {
 		log.Fatal().Err(err).Msg("unable to load tls certificate")
 	}
@@ -40,55 +90,256 @@ func start(cfg config.Config) {
 		panic(err)
 	}
 
-	p2pClient := p2p.NewClient(cert)
-	friendManager := friend.NewManager(cfg.Common, db, p2pClient, nodeID)
+	eventBus := event.NewBus()
+	p2pClient := p2p.NewClient(cert, cfg.P2P)
+	outboxWorker := outbox.NewWorker(cfg.Outbox, db, p2pClient)
+	friendManager := friend.NewManager(cfg.Common, db, p2pClient,
+		outboxWorker, eventBus, nodeID)
+	if err := friendManager.LoadProfile(); err != nil {
+		log.Fatal().Err(err).Msg("unable to load profile")
+	}
+	if err := friendManager.AnnounceHostname(); err != nil {
+		log.Error().Err(err).Msg("error announcing hostname")
+	}
+	letterManager := letter.NewManager(db, p2pClient, outboxWorker,
+		eventBus)
+
+	webhookManager := webhook.NewManager(cfg.Webhook, db, eventBus)
+
+	p2pClient.OnPeerStatus(friendManager.PeerStatus)
+
+	outboxWorker.On(p2p.EventFriendInvite, outbox.Handler{
+		Delivered: friendManager.InviteDelivered,
+	})
+	outboxWorker.On(p2p.EventLetterSend, outbox.Handler{
+		Delivered: letterManager.LetterDelivered,
+		Failed:    letterManager.LetterFailed,
+	})
+
+	outboxDone := make(chan struct{})
+	go func() {
+		outboxWorker.Run(ctx)
+		close(outboxDone)
+	}()
+
+	webhookDone := make(chan struct{})
+	go func() {
+		webhookManager.Run(ctx)
+		close(webhookDone)
+	}()
+
+	p2pServer := newP2PServer(cert, cfg, friendManager, letterManager)
+	go func() {
+		err := p2pServer.Run()
+		if err != nil && !errors.Is(err, p2p.ErrServerClosed) {
+			log.Fatal().Err(err).Msg("error running p2p server")
+		}
+	}()
+
+	managementAuth, err := management.NewAuth(cfg.Management, db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("unable to set up management auth")
+	}
+	if setupRequired, err := managementAuth.SetupRequired(); err != nil {
+		log.Fatal().Err(err).Msg("unable to set up management auth")
+	} else if setupRequired {
+		log.Warn().Msg("management password is not set up, only " +
+			"the setup API is available until it is set with " +
+			"POST /management/setup from the local host or the " +
+			"passwd command")
+	}
+
+	configReloader := &reloader{
+		opts:          opts,
+		current:       cfg,
+		friendManager: friendManager,
+		auth:          managementAuth,
+		p2pServer:     p2pServer,
+	}
+	go configReloader.reloadOnSignal(ctx)
 
-	go startP2PServer(cert, cfg.P2PPort, friendManager)
-	go startManagementServer(cfg, friendManager, nodeID)
+	managementServer := newManagementServer(cfg, managementAuth,
+		friendManager, letterManager, webhookManager, eventBus,
+		configReloader, nodeID)
+	go func() {
+		err := managementServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).
+				Msg("unable to start management server")
+		}
+	}()
+
+	<-ctx.Done()
+	// Restore the default behavior so that another signal terminates the
+	// process immediately.
+	stop()
+	log.Info().Msg("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(),
+		shutdownTimeout)
+	defer cancel()
+
+	// Stop accepting requests before stopping the components that the
+	// requests depend on.
+	if err := managementServer.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).
+			Msg("error shutting down management server")
+	}
+	if err := p2pServer.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("error shutting down p2p server")
+	}
+	<-outboxDone
+	<-webhookDone
+	p2pClient.Close()
 
-	forever := make(chan struct{})
-	<-forever
+	if err := db.Close(); err != nil {
+		log.Error().Err(err).Msg("error closing database")
+	}
+
+	log.Info().Msg("shut down")
 }
 
-func startP2PServer(cert tls.Certificate, port int,
-	friendManager *friend.Manager) {
+// newP2PServer creates a P2P server with all peer handlers registered.
+func newP2PServer(cert tls.Certificate, cfg config.Config,
+	friendManager *friend.Manager,
+	letterManager *letter.Manager) *p2p.Server {
 
-	p2pServer := p2p.NewServer(cert, port)
+	p2pServer := p2p.NewServer(cert, cfg.P2PPort, cfg.P2P)
 
-	peerHandler := &PeerHandler{friendManager: friendManager}
+	peerHandler := &PeerHandler{
+		friendManager: friendManager,
+		letterManager: letterManager,
+	}
 
+	p2pServer.Guard(friendManager.AllowPeer)
+	p2pServer.OnPeerStatus(friendManager.PeerStatus)
 	p2pServer.On(p2p.EventPing, peerHandler.Ping)
 	p2pServer.On(p2p.EventFriendInvite, peerHandler.ReceiveInvite)
+	p2pServer.On(p2p.EventFriendRemove, peerHandler.ReceiveRemove)
+	p2pServer.On(p2p.EventLetterSend, peerHandler.ReceiveLetter)
+	p2pServer.On(p2p.EventProfileUpdate, peerHandler.ReceiveProfileUpdate)
+	p2pServer.On(p2p.EventHostnameUpdate,
+		peerHandler.ReceiveHostnameUpdate)
 
-	if err := p2pServer.Run(); err != nil {
-		log.Fatal().Err(err).Msg("error running p2p server")
-	}
+	return p2pServer
 }
 
-func startManagementServer(cfg config.Config, friendManager *friend.Manager,
-	nodeID string) {
-
-	managementAuth := management.NewAuth(cfg.Management)
+// newManagementServer creates an HTTP server serving the management APIs.
+func newManagementServer(cfg config.Config, managementAuth *management.Auth,
+	friendManager *friend.Manager, letterManager *letter.Manager,
+	webhookManager *webhook.Manager, eventBus *event.Bus,
+	configReloader *reloader, nodeID string) *http.Server {
 
-	r := gin.Default()
+	r := gin.New()
+	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{
+		Formatter: requestLogFormatter,
+	}), gin.Recovery())
 
 	// Management APIs
 	mgmtRouter := r.Group("/management")
 	{
 		mgmtHandler := &ManagementHandler{
-			commonConfig:  cfg.Common,
-			auth:          managementAuth,
-			friendManager: friendManager,
-			nodeID:        nodeID,
+			auth:           managementAuth,
+			friendManager:  friendManager,
+			letterManager:  letterManager,
+			webhookManager: webhookManager,
+			events:         eventBus,
+			reloader:       configReloader,
+			nodeID:         nodeID,
 		}
 		mgmtRouter.POST("/login", mgmtHandler.Login)
+		mgmtRouter.POST("/setup", mgmtHandler.Setup)
+		mgmtRouter.GET("/openapi.json", OpenAPI)
+
+		// Browsers cannot set headers on event streams, so the access
+		// token may be sent in the query instead.
+		mgmtRouter.GET("/events", mgmtHandler.QueryToken,
+			mgmtHandler.Middleware, mgmtHandler.Events)
 
 		mgmtRouter.Use(mgmtHandler.Middleware)
 		mgmtRouter.GET("/identity", mgmtHandler.Identity)
-		mgmtRouter.POST("/people/invite/send", mgmtHandler.SendInvite)
+		mgmtRouter.GET("/profile", mgmtHandler.GetProfile)
+
+		sessionOnly := mgmtHandler.RequireSession
+		mgmtRouter.POST("/logout", sessionOnly, mgmtHandler.Logout)
+		mgmtRouter.POST("/refresh", sessionOnly, mgmtHandler.Refresh)
+
+		admin := mgmtHandler.RequireScope(management.ScopeAdmin)
+		mgmtRouter.GET("/sessions", admin, mgmtHandler.ListSessions)
+		mgmtRouter.DELETE("/sessions", admin,
+			mgmtHandler.RevokeOtherSessions)
+		mgmtRouter.DELETE("/sessions/:id", admin,
+			mgmtHandler.RevokeSession)
+		mgmtRouter.GET("/tokens", admin, mgmtHandler.ListTokens)
+		mgmtRouter.POST("/tokens", admin, mgmtHandler.CreateToken)
+		mgmtRouter.DELETE("/tokens/:id", admin, mgmtHandler.RevokeToken)
+		mgmtRouter.GET("/webhooks", admin, mgmtHandler.ListWebhooks)
+		mgmtRouter.POST("/webhooks", admin, mgmtHandler.CreateWebhook)
+		mgmtRouter.GET("/webhooks/:id", admin, mgmtHandler.GetWebhook)
+		mgmtRouter.DELETE("/webhooks/:id", admin,
+			mgmtHandler.DeleteWebhook)
+		mgmtRouter.GET("/webhooks/:id/deliveries", admin,
+			mgmtHandler.ListWebhookDeliveries)
+		mgmtRouter.POST("/config/reload", admin,
+			mgmtHandler.ReloadConfig)
+		mgmtRouter.PATCH("/profile", admin, mgmtHandler.UpdateProfile)
+
+		peopleRead := mgmtHandler.RequireScope(
+			management.ScopePeopleRead)
+		peopleWrite := mgmtHandler.RequireScope(
+			management.ScopePeopleWrite)
+		mgmtRouter.GET("/people", peopleRead, mgmtHandler.ListFriends)
+		mgmtRouter.GET("/people/:nodeID", peopleRead,
+			mgmtHandler.GetFriend)
+		mgmtRouter.PATCH("/people/:nodeID", peopleWrite,
+			mgmtHandler.UpdateFriend)
+		mgmtRouter.DELETE("/people/:nodeID", peopleWrite,
+			mgmtHandler.RemoveFriend)
+		mgmtRouter.POST("/people/invite/send", peopleWrite,
+			mgmtHandler.SendInvite)
+		mgmtRouter.GET("/people/requests/incoming", peopleRead,
+			mgmtHandler.ListIncomingRequests)
+		mgmtRouter.GET("/people/requests/outgoing", peopleRead,
+			mgmtHandler.ListOutgoingRequests)
+		mgmtRouter.POST("/people/requests/:nodeID/accept", peopleWrite,
+			mgmtHandler.AcceptRequest)
+		mgmtRouter.POST("/people/requests/:nodeID/decline", peopleWrite,
+			mgmtHandler.DeclineRequest)
+		mgmtRouter.POST("/people/requests/:nodeID/cancel", peopleWrite,
+			mgmtHandler.CancelRequest)
+		mgmtRouter.GET("/blocks", peopleRead, mgmtHandler.ListBlocks)
+		mgmtRouter.POST("/blocks", peopleWrite, mgmtHandler.Block)
+		mgmtRouter.DELETE("/blocks/:id", peopleWrite,
+			mgmtHandler.Unblock)
+
+		lettersRead := mgmtHandler.RequireScope(
+			management.ScopeLettersRead)
+		lettersSend := mgmtHandler.RequireScope(
+			management.ScopeLettersSend)
+		lettersWrite := mgmtHandler.RequireScope(
+			management.ScopeLettersWrite)
+		mgmtRouter.POST("/letters/send", lettersSend,
+			mgmtHandler.SendLetter)
+		mgmtRouter.GET("/letters/inbox", lettersRead,
+			mgmtHandler.ListInbox)
+		mgmtRouter.GET("/letters/outbox", lettersRead,
+			mgmtHandler.ListOutbox)
+		mgmtRouter.GET("/letters/:id", lettersRead,
+			mgmtHandler.GetLetter)
+		mgmtRouter.POST("/letters/:id/read", lettersWrite,
+			mgmtHandler.MarkLetterRead)
+		mgmtRouter.POST("/letters/:id/unread", lettersWrite,
+			mgmtHandler.MarkLetterUnread)
+		mgmtRouter.DELETE("/letters/:id", lettersWrite,
+			mgmtHandler.DeleteLetter)
 	}
 
-	if err := r.Run(":" + strconv.Itoa(cfg.Management.Port)); err != nil {
-		log.Fatal().Err(err).Msg("unable to start management server")
+	server := &http.Server{
+		Addr:    ":" + strconv.Itoa(cfg.Management.Port),
+		Handler: r,
 	}
+	// End event streams on shutdown since they never complete by
+	// themselves.
+	server.RegisterOnShutdown(eventBus.Close)
+	return server
 }
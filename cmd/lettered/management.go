@@ -2,15 +2,22 @@ package main
 
 import (
 	"errors"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
-	"github.com/sunboyy/lettered/pkg/common"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/event"
 	"github.com/sunboyy/lettered/pkg/friend"
+	"github.com/sunboyy/lettered/pkg/letter"
 	"github.com/sunboyy/lettered/pkg/management"
+	"github.com/sunboyy/lettered/pkg/management/api"
 	"github.com/sunboyy/lettered/pkg/p2p"
+	"github.com/sunboyy/lettered/pkg/webhook"
 )
 
 var (
@@ -18,6 +25,19 @@ var (
 	// management APIs with valid access token.
 	ErrUnauthorized = errors.New("unauthorized")
 
+	// ErrForbidden is returned when the API token of the request is not
+	// granted the scope required by the management API.
+	ErrForbidden = errors.New("insufficient scope")
+
+	// ErrSessionRequired is returned when the management API can only be
+	// used with a session access token rather than an API token.
+	ErrSessionRequired = errors.New("session required")
+
+	// ErrSetupNotLocal is returned when the setup API is requested from
+	// a host other than the local host.
+	ErrSetupNotLocal = errors.New("setup is only allowed from the local " +
+		"host, or use the passwd command")
+
 	// ErrInvalidRequest is returned when the system cannot bind request
 	// body or request query with the response struct.
 	ErrInvalidRequest = errors.New("invalid request")
@@ -30,34 +50,96 @@ var (
 // ManagementHandler is a set of gin handlers functions that handles management
 // functionality of the system.
 type ManagementHandler struct {
-	commonConfig  common.Config
-	auth          *management.Auth
-	friendManager *friend.Manager
-	nodeID        string
+	auth           *management.Auth
+	friendManager  *friend.Manager
+	letterManager  *letter.Manager
+	webhookManager *webhook.Manager
+	events         *event.Bus
+	reloader       *reloader
+	nodeID         string
 }
 
+const (
+	// sessionKey is the gin context key of the session of the request.
+	sessionKey = "session"
+
+	// apiTokenKey is the gin context key of the API token of the request.
+	apiTokenKey = "apiToken"
+)
+
 // Middleware is an authentication middleware for the management APIs. It
-// rejects all requests with no valid access token.
+// rejects all requests with no valid access token or API token. The session
+// or the API token is stored in the gin context.
 func (h *ManagementHandler) Middleware(ctx *gin.Context) {
 	authHeader := ctx.Request.Header.Get("Authorization")
 	authHeader = strings.TrimPrefix(authHeader, "Bearer ")
-	if !h.auth.AccessTokenValid(authHeader) {
+
+	if management.IsAPIToken(authHeader) {
+		apiToken, ok := h.auth.AuthenticateAPIToken(authHeader)
+		if !ok {
+			abortUnauthorized(ctx)
+			return
+		}
+		ctx.Set(apiTokenKey, apiToken)
+	} else {
+		session, ok := h.auth.Authenticate(authHeader, remoteIP(ctx))
+		if !ok {
+			abortUnauthorized(ctx)
+			return
+		}
+		ctx.Set(sessionKey, session)
+	}
+
+	ctx.Next()
+}
+
+func abortUnauthorized(ctx *gin.Context) {
+	ctx.JSON(
+		http.StatusUnauthorized,
+		gin.H{"error": ErrUnauthorized.Error()},
+	)
+	ctx.Abort()
+}
+
+// RequireScope returns a middleware that rejects requests authenticated with
+// an API token that is not granted the scope. Sessions are granted all
+// scopes.
+func (h *ManagementHandler) RequireScope(
+	scope management.Scope) gin.HandlerFunc {
+
+	return func(ctx *gin.Context) {
+		value, ok := ctx.Get(apiTokenKey)
+		if !ok {
+			return
+		}
+		apiToken, ok := value.(*db.APIToken)
+		if !ok || !management.HasScope(apiToken, scope) {
+			ctx.JSON(
+				http.StatusForbidden,
+				gin.H{"error": ErrForbidden.Error()},
+			)
+			ctx.Abort()
+		}
+	}
+}
+
+// RequireSession is a middleware that rejects requests authenticated with an
+// API token.
+func (h *ManagementHandler) RequireSession(ctx *gin.Context) {
+	if currentSession(ctx) == nil {
 		ctx.JSON(
-			http.StatusUnauthorized,
-			gin.H{"error": ErrUnauthorized.Error()},
+			http.StatusForbidden,
+			gin.H{"error": ErrSessionRequired.Error()},
 		)
 		ctx.Abort()
-		return
 	}
-
-	ctx.Next()
 }
 
 // Login is a gin handler for logging in to the management console. It receives
 // a password from the request body, generates a new access token if the
 // given password is correct.
 func (h *ManagementHandler) Login(ctx *gin.Context) {
-	var req ManagementLoginRequest
+	var req api.ManagementLoginRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(
 			http.StatusBadRequest,
@@ -66,13 +148,26 @@ func (h *ManagementHandler) Login(ctx *gin.Context) {
 		return
 	}
 
-	accessToken, err := h.auth.Login(req.Password)
+	accessToken, session, err := h.auth.Login(req.Password, remoteIP(ctx))
 	if err != nil {
-		if errors.Is(err, management.ErrIncorrectPassword) {
+		var lockedOutErr *management.LockedOutError
+		if errors.As(err, &lockedOutErr) {
+			ctx.Header("Retry-After",
+				retryAfterSeconds(lockedOutErr.RetryAfter))
+			ctx.JSON(
+				http.StatusTooManyRequests,
+				gin.H{"error": err.Error()},
+			)
+		} else if errors.Is(err, management.ErrIncorrectPassword) {
 			ctx.JSON(
 				http.StatusBadRequest,
 				gin.H{"error": err.Error()},
 			)
+		} else if errors.Is(err, management.ErrSetupRequired) {
+			ctx.JSON(
+				http.StatusForbidden,
+				gin.H{"error": err.Error()},
+			)
 		} else {
 			log.Warn().Err(err).Msg("error processing login")
 			ctx.JSON(
@@ -83,39 +178,101 @@ func (h *ManagementHandler) Login(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, ManagementLoginResponse{
+	ctx.JSON(http.StatusOK, api.ManagementLoginResponse{
+		AccessToken: accessToken,
+		ExpiresAt:   session.ExpiresAt,
+	})
+}
+
+// Setup is a gin handler for setting up the administrator password on the
+// first run. It is only allowed from the local host while no password has been
+// set up, so that no one else reaching the management port can take over the
+// node, and it logs in with the new password.
+func (h *ManagementHandler) Setup(ctx *gin.Context) {
+	if ip := net.ParseIP(remoteIP(ctx)); ip == nil || !ip.IsLoopback() {
+		ctx.JSON(
+			http.StatusForbidden,
+			gin.H{"error": ErrSetupNotLocal.Error()},
+		)
+		return
+	}
+
+	var req api.ManagementSetupRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return
+	}
+
+	accessToken, session, err := h.auth.Setup(req.Password, remoteIP(ctx))
+	if err != nil {
+		if errors.Is(err, management.ErrPasswordTooShort) ||
+			errors.Is(err, management.ErrDefaultPassword) {
+
+			ctx.JSON(
+				http.StatusBadRequest,
+				gin.H{"error": err.Error()},
+			)
+		} else if errors.Is(err, management.ErrAlreadySetUp) {
+			ctx.JSON(
+				http.StatusConflict,
+				gin.H{"error": err.Error()},
+			)
+		} else {
+			log.Warn().Err(err).Msg("error processing setup")
+			ctx.JSON(
+				http.StatusInternalServerError,
+				gin.H{"error": ErrInternalServerError.Error()},
+			)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, api.ManagementLoginResponse{
 		AccessToken: accessToken,
+		ExpiresAt:   session.ExpiresAt,
 	})
 }
 
-// ManagementLoginRequest defines a request body of the management login API.
-type ManagementLoginRequest struct {
-	Password string `json:"password"`
+// remoteIP returns the IP address of the connection of the request. Unlike
+// gin's ClientIP, forwarding headers are not trusted since they can be forged
+// to evade the login lockout.
+func remoteIP(ctx *gin.Context) string {
+	host, _, err := net.SplitHostPort(ctx.Request.RemoteAddr)
+	if err != nil {
+		return ctx.Request.RemoteAddr
+	}
+	return host
 }
 
-// ManagementLoginResponse defines a response body of the management login API.
-type ManagementLoginResponse struct {
-	AccessToken string `json:"accessToken"`
+// retryAfterSeconds formats a duration as the value of the Retry-After header
+// in whole seconds, rounded up.
+func retryAfterSeconds(d time.Duration) string {
+	seconds := (d + time.Second - 1) / time.Second
+	return strconv.FormatInt(int64(seconds), 10)
 }
 
 // Identity is a gin handler returning the user's identifier for other people
 // to connect.
 func (h *ManagementHandler) Identity(ctx *gin.Context) {
-	ctx.JSON(http.StatusOK, IdentityResponse{
+	ctx.JSON(http.StatusOK, api.IdentityResponse{
 		Identifier: p2p.CreateIdentifier(
 			h.nodeID,
-			h.commonConfig.Hostname,
+			h.friendManager.CommonConfig().Hostname,
 		),
 	})
 }
 
-// IdentityResponse defines response body of the identity management API.
-type IdentityResponse struct {
-	Identifier string `json:"identifier"`
+// OpenAPI is a gin handler returning the OpenAPI document of the management
+// API. It does not require authentication.
+func OpenAPI(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "application/json", api.OpenAPI)
 }
 
 func (h *ManagementHandler) SendInvite(ctx *gin.Context) {
-	var req SendInviteRequest
+	var req api.SendInviteRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(
 			http.StatusBadRequest,
@@ -124,7 +281,11 @@ func (h *ManagementHandler) SendInvite(ctx *gin.Context) {
 		return
 	}
 
-	if err := h.friendManager.SendInvite(req.Identifier); err != nil {
+	err := h.friendManager.SendInvite(ctx.Request.Context(), req.Identifier)
+	if err != nil {
+		if respondPeerError(ctx, err) {
+			return
+		}
 		if errors.Is(err, friend.ErrInvalidIdentifier) ||
 			errors.Is(err, friend.ErrInviteSelf) ||
 			errors.Is(err, friend.ErrAlreadyFriend) {
@@ -146,6 +307,21 @@ func (h *ManagementHandler) SendInvite(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{})
 }
 
-type SendInviteRequest struct {
-	Identifier string `json:"identifier"`
+// respondPeerError responds with the error returned by a peer and returns true
+// if err is a p2p.ResponseError. Otherwise, it returns false without
+// responding.
+func respondPeerError(ctx *gin.Context, err error) bool {
+	var respErr *p2p.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+
+	ctx.JSON(
+		http.StatusBadGateway,
+		gin.H{
+			"error":         respErr.Message,
+			"peerErrorCode": respErr.Code,
+		},
+	)
+	return true
 }
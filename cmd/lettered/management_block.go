@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/friend"
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// ListBlocks is a gin handler returning all blocked node IDs and hosts.
+func (h *ManagementHandler) ListBlocks(ctx *gin.Context) {
+	blocks, err := h.friendManager.ListBlocks()
+	if err != nil {
+		log.Warn().Err(err).Msg("error listing blocks")
+		ctx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": ErrInternalServerError.Error()},
+		)
+		return
+	}
+
+	res := api.ListBlocksResponse{
+		Blocks: make([]api.BlockResponse, 0, len(blocks)),
+	}
+	for i := range blocks {
+		res.Blocks = append(res.Blocks, newBlockResponse(&blocks[i]))
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+func newBlockResponse(block *db.Block) api.BlockResponse {
+	return api.BlockResponse{
+		ID:        block.ID,
+		NodeID:    block.NodeID,
+		Host:      block.Host,
+		CreatedAt: block.CreatedAt,
+	}
+}
+
+// Block is a gin handler blocking a node ID or a host from communicating with
+// the user.
+func (h *ManagementHandler) Block(ctx *gin.Context) {
+	var req api.BlockRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return
+	}
+
+	block, err := h.friendManager.Block(req.NodeID, req.Host)
+	if err != nil {
+		if errors.Is(err, friend.ErrEmptyBlock) {
+			ctx.JSON(
+				http.StatusBadRequest,
+				gin.H{"error": err.Error()},
+			)
+		} else {
+			log.Warn().Err(err).Msg("error blocking")
+			ctx.JSON(
+				http.StatusInternalServerError,
+				gin.H{"error": ErrInternalServerError.Error()},
+			)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newBlockResponse(block))
+}
+
+// Unblock is a gin handler deleting the block with the ID specified in the
+// path.
+func (h *ManagementHandler) Unblock(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 0)
+	if err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return
+	}
+
+	if err := h.friendManager.Unblock(uint(id)); err != nil {
+		if errors.Is(err, friend.ErrBlockNotFound) {
+			ctx.JSON(
+				http.StatusNotFound,
+				gin.H{"error": err.Error()},
+			)
+		} else {
+			log.Warn().Err(err).Msg("error unblocking")
+			ctx.JSON(
+				http.StatusInternalServerError,
+				gin.H{"error": ErrInternalServerError.Error()},
+			)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/config"
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// ReloadConfig is a gin handler reading the config again and applying the
+// changed options that do not require a restart. Nothing is applied if the
+// config is invalid.
+func (h *ManagementHandler) ReloadConfig(ctx *gin.Context) {
+	applied, restartRequired, overridden, err := h.reloader.Reload()
+	if err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) ||
+			errors.Is(err, config.ErrConfigNotFound) ||
+			errors.Is(err, config.ErrUnknownOption) {
+
+			ctx.JSON(
+				http.StatusBadRequest,
+				gin.H{"error": err.Error()},
+			)
+			return
+		}
+
+		log.Warn().Err(err).Msg("error reloading config")
+		ctx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": ErrInternalServerError.Error()},
+		)
+		return
+	}
+
+	log.Info().Strs("applied", applied).
+		Strs("restartRequired", restartRequired).
+		Strs("overridden", overridden).
+		Msg("config reloaded")
+	ctx.JSON(http.StatusOK, api.ReloadConfigResponse{
+		Applied:         applied,
+		RestartRequired: restartRequired,
+		Overridden:      overridden,
+	})
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/event"
+	"github.com/sunboyy/lettered/pkg/management"
+)
+
+const (
+	// eventHeartbeatInterval is the interval of heartbeats sent on idle
+	// event streams so that proxies and clients do not time out.
+	eventHeartbeatInterval = 30 * time.Second
+
+	// eventWriteTimeout is the maximum duration for writing a message to a
+	// WebSocket event stream.
+	eventWriteTimeout = 10 * time.Second
+)
+
+// eventScopes are the scopes required for receiving each type of events with
+// an API token.
+var eventScopes = map[event.Type]management.Scope{
+	event.TypeFriendRequestReceived: management.ScopePeopleRead,
+	event.TypeFriendAccepted:        management.ScopePeopleRead,
+	event.TypePeerOnline:            management.ScopePeopleRead,
+	event.TypePeerOffline:           management.ScopePeopleRead,
+	event.TypeLetterReceived:        management.ScopeLettersRead,
+	event.TypeDeliveryFailed:        management.ScopeLettersRead,
+}
+
+// eventUpgrader upgrades event stream requests to WebSocket. Cross-origin
+// requests are rejected.
+var eventUpgrader = websocket.Upgrader{}
+
+// QueryToken is a middleware that takes the access token from the
+// access_token query parameter when the request has no Authorization header.
+// It is for clients that cannot set headers, such as EventSource and
+// WebSocket in browsers.
+func (h *ManagementHandler) QueryToken(ctx *gin.Context) {
+	if ctx.GetHeader("Authorization") != "" {
+		return
+	}
+	if token := ctx.Query("access_token"); token != "" {
+		ctx.Request.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// Events is a gin handler streaming events to the management client until the
+// client disconnects. The stream is a WebSocket if the client requests an
+// upgrade, or Server-Sent Events otherwise. The types query parameter is an
+// optional comma-separated list of the event types to receive. API tokens
+// only receive the events that their scopes allow reading.
+func (h *ManagementHandler) Events(ctx *gin.Context) {
+	allow := eventFilter(ctx)
+
+	sub := h.events.Subscribe()
+	defer sub.Close()
+
+	if websocket.IsWebSocketUpgrade(ctx.Request) {
+		streamWebSocket(ctx, sub, allow)
+		return
+	}
+	streamSSE(ctx, sub, allow)
+}
+
+// eventFilter returns a function reporting whether an event of the type is
+// sent to the client of the request.
+func eventFilter(ctx *gin.Context) func(event.Type) bool {
+	var types map[event.Type]bool
+	if param := ctx.Query("types"); param != "" {
+		types = map[event.Type]bool{}
+		for _, t := range strings.Split(param, ",") {
+			types[event.Type(strings.TrimSpace(t))] = true
+		}
+	}
+
+	var apiToken *db.APIToken
+	if value, ok := ctx.Get(apiTokenKey); ok {
+		apiToken, _ = value.(*db.APIToken)
+	}
+
+	return func(t event.Type) bool {
+		if types != nil && !types[t] {
+			return false
+		}
+		if apiToken != nil {
+			scope, ok := eventScopes[t]
+			return ok && management.HasScope(apiToken, scope)
+		}
+		return true
+	}
+}
+
+// streamSSE sends the events as Server-Sent Events. The event ID is the ID of
+// the event, the event name is its type and the data is the event in JSON.
+func streamSSE(ctx *gin.Context, sub *event.Subscription,
+	allow func(event.Type) bool) {
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if !allow(e.Type) {
+				continue
+			}
+			if err := sse.Encode(ctx.Writer, sse.Event{
+				Id:    strconv.FormatUint(e.ID, 10),
+				Event: string(e.Type),
+				Data:  e,
+			}); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := ctx.Writer.WriteString(
+				": heartbeat\n\n"); err != nil {
+
+				return
+			}
+		}
+		ctx.Writer.Flush()
+	}
+}
+
+// streamWebSocket upgrades the connection to WebSocket and sends each event as
+// a JSON text message. Messages from the client are discarded.
+func streamWebSocket(ctx *gin.Context, sub *event.Subscription,
+	allow func(event.Type) bool) {
+
+	conn, err := eventUpgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// The upgrader has responded with an HTTP error.
+		log.Debug().Err(err).Msg("error upgrading event stream")
+		return
+	}
+	defer conn.Close()
+
+	// Read in the background to handle control messages and to find out
+	// when the client goes away. The client is considered gone if it
+	// does not answer the heartbeat pings.
+	readTimeout := 2 * eventHeartbeatInterval
+	_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+	clientGone := make(chan struct{})
+	go func() {
+		defer close(clientGone)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-clientGone:
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(
+						websocket.CloseGoingAway, ""),
+					time.Now().Add(eventWriteTimeout))
+				return
+			}
+			if !allow(e.Type) {
+				continue
+			}
+			_ = conn.SetWriteDeadline(
+				time.Now().Add(eventWriteTimeout))
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil,
+				time.Now().Add(eventWriteTimeout)); err != nil {
+
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/friend"
+	"github.com/sunboyy/lettered/pkg/management/api"
+	"github.com/sunboyy/lettered/pkg/p2p"
+)
+
+// ListFriends is a gin handler returning all friends of the user.
+func (h *ManagementHandler) ListFriends(ctx *gin.Context) {
+	friends, err := h.friendManager.ListFriends()
+	if err != nil {
+		log.Warn().Err(err).Msg("error listing friends")
+		ctx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": ErrInternalServerError.Error()},
+		)
+		return
+	}
+
+	res := api.ListFriendsResponse{
+		Friends: make([]api.FriendResponse, 0, len(friends)),
+	}
+	for i := range friends {
+		res.Friends = append(res.Friends,
+			newFriendResponse(&friends[i]))
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+func newFriendResponse(friend *db.Friend) api.FriendResponse {
+	identifier := p2p.CreateIdentifier(friend.NodeID, friend.Hostname)
+	return api.FriendResponse{
+		NodeID:     friend.NodeID,
+		Identifier: identifier,
+		Hostname:   friend.Hostname,
+		Alias:      friend.Alias,
+		Nickname:   friend.Nickname,
+		Bio:        friend.Bio,
+		Avatar:     friend.Avatar,
+		CreatedAt:  friend.CreatedAt,
+	}
+}
+
+// GetFriend is a gin handler returning the friend with the node ID specified
+// in the path.
+func (h *ManagementHandler) GetFriend(ctx *gin.Context) {
+	foundFriend, err := h.friendManager.GetFriend(ctx.Param("nodeID"))
+	if err != nil {
+		respondFriendError(ctx, err, "error getting friend")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newFriendResponse(foundFriend))
+}
+
+// UpdateFriend is a gin handler updating the local settings of the friend with
+// the node ID specified in the path.
+func (h *ManagementHandler) UpdateFriend(ctx *gin.Context) {
+	var req api.UpdateFriendRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return
+	}
+
+	updatedFriend, err := h.friendManager.SetNickname(ctx.Param("nodeID"),
+		req.Nickname)
+	if err != nil {
+		respondFriendError(ctx, err, "error updating friend")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newFriendResponse(updatedFriend))
+}
+
+// RemoveFriend is a gin handler removing the friend with the node ID specified
+// in the path. The peer is notified to remove the user as well.
+func (h *ManagementHandler) RemoveFriend(ctx *gin.Context) {
+	err := h.friendManager.RemoveFriend(ctx.Request.Context(),
+		ctx.Param("nodeID"))
+	if err != nil {
+		respondFriendError(ctx, err, "error removing friend")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+func respondFriendError(ctx *gin.Context, err error, msg string) {
+	if errors.Is(err, friend.ErrFriendNotFound) {
+		ctx.JSON(
+			http.StatusNotFound,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	log.Warn().Err(err).Msg(msg)
+	ctx.JSON(
+		http.StatusInternalServerError,
+		gin.H{"error": ErrInternalServerError.Error()},
+	)
+}
+
+// ListIncomingRequests is a gin handler returning pending friend requests sent
+// by peers to the user.
+func (h *ManagementHandler) ListIncomingRequests(ctx *gin.Context) {
+	friendReqs, err := h.friendManager.ListIncomingRequests()
+	if err != nil {
+		log.Warn().Err(err).
+			Msg("error listing incoming friend requests")
+		ctx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": ErrInternalServerError.Error()},
+		)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newListFriendRequestsResponse(friendReqs))
+}
+
+// ListOutgoingRequests is a gin handler returning pending friend requests sent
+// by the user to peers.
+func (h *ManagementHandler) ListOutgoingRequests(ctx *gin.Context) {
+	friendReqs, err := h.friendManager.ListOutgoingRequests()
+	if err != nil {
+		log.Warn().Err(err).
+			Msg("error listing outgoing friend requests")
+		ctx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": ErrInternalServerError.Error()},
+		)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newListFriendRequestsResponse(friendReqs))
+}
+
+func newListFriendRequestsResponse(
+	friendReqs []db.FriendRequest) api.ListFriendRequestsResponse {
+
+	res := api.ListFriendRequestsResponse{
+		Requests: make([]api.FriendRequestResponse, 0, len(friendReqs)),
+	}
+	for _, friendReq := range friendReqs {
+		res.Requests = append(res.Requests, api.FriendRequestResponse{
+			NodeID:    friendReq.NodeID,
+			Hostname:  friendReq.Hostname,
+			Alias:     friendReq.Alias,
+			UpdatedAt: friendReq.UpdatedAt,
+		})
+	}
+	return res
+}
+
+// AcceptRequest is a gin handler accepting an incoming friend request from the
+// peer with the node ID specified in the path.
+func (h *ManagementHandler) AcceptRequest(ctx *gin.Context) {
+	err := h.friendManager.AcceptRequest(ctx.Request.Context(),
+		ctx.Param("nodeID"))
+	respondFriendRequestResult(ctx, err, "error accepting friend request")
+}
+
+// DeclineRequest is a gin handler declining an incoming friend request from
+// the peer with the node ID specified in the path.
+func (h *ManagementHandler) DeclineRequest(ctx *gin.Context) {
+	err := h.friendManager.DeclineRequest(ctx.Param("nodeID"))
+	respondFriendRequestResult(ctx, err, "error declining friend request")
+}
+
+// CancelRequest is a gin handler cancelling an outgoing friend request to the
+// peer with the node ID specified in the path.
+func (h *ManagementHandler) CancelRequest(ctx *gin.Context) {
+	err := h.friendManager.CancelRequest(ctx.Param("nodeID"))
+	respondFriendRequestResult(ctx, err, "error cancelling friend request")
+}
+
+func respondFriendRequestResult(ctx *gin.Context, err error, msg string) {
+	if err == nil {
+		ctx.JSON(http.StatusOK, gin.H{})
+		return
+	}
+
+	if respondPeerError(ctx, err) {
+		return
+	}
+
+	if errors.Is(err, friend.ErrRequestNotFound) {
+		ctx.JSON(
+			http.StatusNotFound,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	log.Warn().Err(err).Msg(msg)
+	ctx.JSON(
+		http.StatusInternalServerError,
+		gin.H{"error": ErrInternalServerError.Error()},
+	)
+}
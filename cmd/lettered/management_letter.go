@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/letter"
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+const (
+	// defaultPageSize is the number of items returned by the list APIs when
+	// the page size is not specified.
+	defaultPageSize = 20
+
+	// maxPageSize is the maximum number of items that the list APIs can
+	// return at once.
+	maxPageSize = 100
+)
+
+// SendLetter is a gin handler for composing a letter and delivering it to
+// a friend.
+func (h *ManagementHandler) SendLetter(ctx *gin.Context) {
+	var req api.SendLetterRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return
+	}
+
+	sentLetter, err := h.letterManager.SendLetter(ctx.Request.Context(),
+		req.NodeID, req.Subject, req.Body)
+	if err != nil {
+		if respondPeerError(ctx, err) {
+			return
+		}
+		if errors.Is(err, letter.ErrNotFriend) ||
+			errors.Is(err, letter.ErrEmptyLetter) ||
+			errors.Is(err, letter.ErrLetterRejected) {
+
+			ctx.JSON(
+				http.StatusBadRequest,
+				gin.H{"error": err.Error()},
+			)
+		} else {
+			log.Warn().Err(err).Msg("error sending letter")
+			ctx.JSON(
+				http.StatusInternalServerError,
+				gin.H{"error": ErrInternalServerError.Error()},
+			)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newLetterResponse(sentLetter))
+}
+
+func newLetterResponse(letter *db.Letter) api.LetterResponse {
+	return api.LetterResponse{
+		ID:       letter.ID,
+		LetterID: letter.LetterID,
+		NodeID:   letter.NodeID,
+		Box:      string(letter.Box),
+		Status:   string(letter.Status),
+		Subject:  letter.Subject,
+		Body:     letter.Body,
+		SentAt:   letter.SentAt,
+		IsRead:   letter.IsRead,
+	}
+}
+
+// ListInbox is a gin handler returning letters received from friends.
+func (h *ManagementHandler) ListInbox(ctx *gin.Context) {
+	h.listLetters(ctx, db.LetterBoxInbox)
+}
+
+// ListOutbox is a gin handler returning letters sent to friends.
+func (h *ManagementHandler) ListOutbox(ctx *gin.Context) {
+	h.listLetters(ctx, db.LetterBoxOutbox)
+}
+
+func (h *ManagementHandler) listLetters(ctx *gin.Context, box db.LetterBox) {
+	var query PaginationQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return
+	}
+	query.normalize()
+
+	letters, total, err := h.letterManager.ListLetters(box, query.offset(),
+		query.PageSize)
+	if err != nil {
+		log.Warn().Err(err).Msg("error listing letters")
+		ctx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": ErrInternalServerError.Error()},
+		)
+		return
+	}
+
+	res := api.ListLettersResponse{
+		Letters:  make([]api.LetterResponse, 0, len(letters)),
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Total:    total,
+	}
+	for i := range letters {
+		res.Letters = append(res.Letters,
+			newLetterResponse(&letters[i]))
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+// PaginationQuery defines query parameters of the list APIs.
+type PaginationQuery struct {
+	Page     int `form:"page"`
+	PageSize int `form:"pageSize"`
+}
+
+// normalize replaces out-of-range pagination parameters with the nearest
+// acceptable values.
+func (q *PaginationQuery) normalize() {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize < 1 {
+		q.PageSize = defaultPageSize
+	}
+	if q.PageSize > maxPageSize {
+		q.PageSize = maxPageSize
+	}
+}
+
+func (q *PaginationQuery) offset() int {
+	return (q.Page - 1) * q.PageSize
+}
+
+// GetLetter is a gin handler returning a letter with the ID specified in the
+// path.
+func (h *ManagementHandler) GetLetter(ctx *gin.Context) {
+	id, ok := letterIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	foundLetter, err := h.letterManager.GetLetter(id)
+	if err != nil {
+		respondLetterError(ctx, err, "error getting letter")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newLetterResponse(foundLetter))
+}
+
+// MarkLetterRead is a gin handler marking a letter as read.
+func (h *ManagementHandler) MarkLetterRead(ctx *gin.Context) {
+	h.markLetter(ctx, true)
+}
+
+// MarkLetterUnread is a gin handler marking a letter as unread.
+func (h *ManagementHandler) MarkLetterUnread(ctx *gin.Context) {
+	h.markLetter(ctx, false)
+}
+
+func (h *ManagementHandler) markLetter(ctx *gin.Context, isRead bool) {
+	id, ok := letterIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	markedLetter, err := h.letterManager.MarkRead(id, isRead)
+	if err != nil {
+		respondLetterError(ctx, err, "error marking letter")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newLetterResponse(markedLetter))
+}
+
+// DeleteLetter is a gin handler deleting a letter with the ID specified in
+// the path.
+func (h *ManagementHandler) DeleteLetter(ctx *gin.Context) {
+	id, ok := letterIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.letterManager.DeleteLetter(id); err != nil {
+		respondLetterError(ctx, err, "error deleting letter")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+// letterIDParam parses the letter ID from the request path. If the ID is
+// invalid, it responds with a bad request error and returns false.
+func letterIDParam(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 0)
+	if err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondLetterError(ctx *gin.Context, err error, msg string) {
+	if errors.Is(err, letter.ErrLetterNotFound) {
+		ctx.JSON(
+			http.StatusNotFound,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	log.Warn().Err(err).Msg(msg)
+	ctx.JSON(
+		http.StatusInternalServerError,
+		gin.H{"error": ErrInternalServerError.Error()},
+	)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/friend"
+	"github.com/sunboyy/lettered/pkg/management/api"
+	"github.com/sunboyy/lettered/pkg/p2p"
+)
+
+// GetProfile is a gin handler returning the profile of the user that is shared
+// with friends.
+func (h *ManagementHandler) GetProfile(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, h.newProfileResponse(h.friendManager.Profile()))
+}
+
+// UpdateProfile is a gin handler changing the fields of the profile specified
+// in the request body. The new profile is sent to all friends.
+func (h *ManagementHandler) UpdateProfile(ctx *gin.Context) {
+	var req api.UpdateProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return
+	}
+
+	profile, err := h.friendManager.UpdateProfile(friend.ProfileUpdate{
+		Alias:    req.Alias,
+		Hostname: req.Hostname,
+		Bio:      req.Bio,
+		Avatar:   req.Avatar,
+	})
+	if err != nil {
+		if errors.Is(err, friend.ErrInvalidProfile) {
+			ctx.JSON(
+				http.StatusBadRequest,
+				gin.H{"error": err.Error()},
+			)
+			return
+		}
+
+		log.Warn().Err(err).Msg("error updating profile")
+		ctx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": ErrInternalServerError.Error()},
+		)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, h.newProfileResponse(profile))
+}
+
+func (h *ManagementHandler) newProfileResponse(
+	profile friend.Profile) api.ProfileResponse {
+
+	return api.ProfileResponse{
+		Identifier: p2p.CreateIdentifier(h.nodeID, profile.Hostname),
+		Alias:      profile.Alias,
+		Hostname:   profile.Hostname,
+		Bio:        profile.Bio,
+		Avatar:     profile.Avatar,
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/management"
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// Logout is a gin handler revoking the session of the request.
+func (h *ManagementHandler) Logout(ctx *gin.Context) {
+	// The session is only missing if it has been revoked concurrently.
+	_ = h.auth.RevokeSession(currentSession(ctx).ID)
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+// Refresh is a gin handler extending the expiration of the session of the
+// request. The access token stays the same.
+func (h *ManagementHandler) Refresh(ctx *gin.Context) {
+	session, err := h.auth.Refresh(currentSession(ctx).ID)
+	if err != nil {
+		respondSessionError(ctx, err, "error refreshing session")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newSessionResponse(session, session.ID))
+}
+
+// ListSessions is a gin handler returning all active sessions of the
+// management console.
+func (h *ManagementHandler) ListSessions(ctx *gin.Context) {
+	sessions := h.auth.ListSessions()
+	currentID := currentSessionID(ctx)
+
+	res := api.ListSessionsResponse{
+		Sessions: make([]api.SessionResponse, 0, len(sessions)),
+	}
+	for i := range sessions {
+		res.Sessions = append(res.Sessions,
+			newSessionResponse(&sessions[i], currentID))
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+func newSessionResponse(session *db.Session,
+	currentID string) api.SessionResponse {
+
+	return api.SessionResponse{
+		ID:         session.ID,
+		ClientIP:   session.ClientIP,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+		Current:    session.ID == currentID,
+	}
+}
+
+// RevokeSession is a gin handler revoking the session with the ID specified in
+// the path.
+func (h *ManagementHandler) RevokeSession(ctx *gin.Context) {
+	if err := h.auth.RevokeSession(ctx.Param("id")); err != nil {
+		respondSessionError(ctx, err, "error revoking session")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+// RevokeOtherSessions is a gin handler revoking all sessions except the
+// session of the request. If the request is authenticated with an API token,
+// all sessions are revoked.
+func (h *ManagementHandler) RevokeOtherSessions(ctx *gin.Context) {
+	count := h.auth.RevokeOtherSessions(currentSessionID(ctx))
+
+	ctx.JSON(http.StatusOK, api.RevokeSessionsResponse{Revoked: count})
+}
+
+func respondSessionError(ctx *gin.Context, err error, msg string) {
+	if errors.Is(err, management.ErrSessionNotFound) {
+		ctx.JSON(
+			http.StatusNotFound,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	log.Warn().Err(err).Msg(msg)
+	ctx.JSON(
+		http.StatusInternalServerError,
+		gin.H{"error": ErrInternalServerError.Error()},
+	)
+}
+
+// currentSession returns the session of the request set by the middleware. It
+// returns nil if the request is authenticated with an API token.
+func currentSession(ctx *gin.Context) *db.Session {
+	value, ok := ctx.Get(sessionKey)
+	if !ok {
+		return nil
+	}
+	session, _ := value.(*db.Session)
+	return session
+}
+
+// currentSessionID returns the ID of the session of the request, or an empty
+// string if the request is authenticated with an API token.
+func currentSessionID(ctx *gin.Context) string {
+	if session := currentSession(ctx); session != nil {
+		return session.ID
+	}
+	return ""
+}
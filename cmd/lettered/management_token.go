@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/management"
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// CreateToken is a gin handler creating a named API token with scopes and an
+// optional expiration. The token is only returned in this response.
+func (h *ManagementHandler) CreateToken(ctx *gin.Context) {
+	var req api.CreateTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return
+	}
+
+	scopes := make([]management.Scope, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		scopes = append(scopes, management.Scope(scope))
+	}
+
+	token, apiToken, err := h.auth.CreateAPIToken(req.Name, scopes,
+		req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, management.ErrEmptyTokenName) ||
+			errors.Is(err, management.ErrNoScope) ||
+			errors.Is(err, management.ErrInvalidScope) ||
+			errors.Is(err, management.ErrExpiredToken) {
+
+			ctx.JSON(
+				http.StatusBadRequest,
+				gin.H{"error": err.Error()},
+			)
+			return
+		}
+
+		log.Warn().Err(err).Msg("error creating api token")
+		ctx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": ErrInternalServerError.Error()},
+		)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, api.CreateTokenResponse{
+		Token:         token,
+		TokenResponse: newTokenResponse(apiToken),
+	})
+}
+
+// ListTokens is a gin handler returning all API tokens without the tokens
+// themselves.
+func (h *ManagementHandler) ListTokens(ctx *gin.Context) {
+	tokens, err := h.auth.ListAPITokens()
+	if err != nil {
+		log.Warn().Err(err).Msg("error listing api tokens")
+		ctx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": ErrInternalServerError.Error()},
+		)
+		return
+	}
+
+	res := api.ListTokensResponse{
+		Tokens: make([]api.TokenResponse, 0, len(tokens)),
+	}
+	for i := range tokens {
+		res.Tokens = append(res.Tokens, newTokenResponse(&tokens[i]))
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+func newTokenResponse(token *db.APIToken) api.TokenResponse {
+	scopes := management.TokenScopes(token)
+	scopeStrings := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scopeStrings = append(scopeStrings, string(scope))
+	}
+
+	return api.TokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		Scopes:     scopeStrings,
+		CreatedAt:  token.CreatedAt,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+	}
+}
+
+// RevokeToken is a gin handler revoking the API token with the ID specified
+// in the path.
+func (h *ManagementHandler) RevokeToken(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 0)
+	if err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return
+	}
+
+	if err := h.auth.RevokeAPIToken(uint(id)); err != nil {
+		if errors.Is(err, management.ErrTokenNotFound) {
+			ctx.JSON(
+				http.StatusNotFound,
+				gin.H{"error": err.Error()},
+			)
+			return
+		}
+
+		log.Warn().Err(err).Msg("error revoking api token")
+		ctx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": ErrInternalServerError.Error()},
+		)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
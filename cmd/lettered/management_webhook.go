@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/event"
+	"github.com/sunboyy/lettered/pkg/management/api"
+	"github.com/sunboyy/lettered/pkg/webhook"
+)
+
+// CreateWebhook is a gin handler registering a webhook that receives events
+// of the specified types. The secret for verifying the payloads is only
+// returned in this response.
+func (h *ManagementHandler) CreateWebhook(ctx *gin.Context) {
+	var req api.CreateWebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return
+	}
+
+	events := make([]event.Type, 0, len(req.Events))
+	for _, e := range req.Events {
+		events = append(events, event.Type(e))
+	}
+
+	createdWebhook, err := h.webhookManager.CreateWebhook(req.URL, events)
+	if err != nil {
+		if errors.Is(err, webhook.ErrInvalidURL) ||
+			errors.Is(err, webhook.ErrNoEvent) ||
+			errors.Is(err, webhook.ErrInvalidEvent) {
+
+			ctx.JSON(
+				http.StatusBadRequest,
+				gin.H{"error": err.Error()},
+			)
+			return
+		}
+
+		log.Warn().Err(err).Msg("error creating webhook")
+		ctx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": ErrInternalServerError.Error()},
+		)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, api.CreateWebhookResponse{
+		Secret:          createdWebhook.Secret,
+		WebhookResponse: newWebhookResponse(createdWebhook),
+	})
+}
+
+// ListWebhooks is a gin handler returning all webhooks without their secrets.
+func (h *ManagementHandler) ListWebhooks(ctx *gin.Context) {
+	webhooks, err := h.webhookManager.ListWebhooks()
+	if err != nil {
+		log.Warn().Err(err).Msg("error listing webhooks")
+		ctx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": ErrInternalServerError.Error()},
+		)
+		return
+	}
+
+	res := api.ListWebhooksResponse{
+		Webhooks: make([]api.WebhookResponse, 0, len(webhooks)),
+	}
+	for i := range webhooks {
+		res.Webhooks = append(res.Webhooks,
+			newWebhookResponse(&webhooks[i]))
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+func newWebhookResponse(w *db.Webhook) api.WebhookResponse {
+	events := webhook.Events(w)
+	eventStrings := make([]string, 0, len(events))
+	for _, e := range events {
+		eventStrings = append(eventStrings, string(e))
+	}
+
+	return api.WebhookResponse{
+		ID:        w.ID,
+		URL:       w.URL,
+		Events:    eventStrings,
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+// GetWebhook is a gin handler returning a webhook with the ID specified in
+// the path.
+func (h *ManagementHandler) GetWebhook(ctx *gin.Context) {
+	id, ok := webhookIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	foundWebhook, err := h.webhookManager.GetWebhook(id)
+	if err != nil {
+		respondWebhookError(ctx, err, "error getting webhook")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newWebhookResponse(foundWebhook))
+}
+
+// DeleteWebhook is a gin handler deleting a webhook with the ID specified in
+// the path together with its delivery log.
+func (h *ManagementHandler) DeleteWebhook(ctx *gin.Context) {
+	id, ok := webhookIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.webhookManager.DeleteWebhook(id); err != nil {
+		respondWebhookError(ctx, err, "error deleting webhook")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+// ListWebhookDeliveries is a gin handler returning the delivery log of a
+// webhook with the ID specified in the path, newest first.
+func (h *ManagementHandler) ListWebhookDeliveries(ctx *gin.Context) {
+	id, ok := webhookIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	var query PaginationQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return
+	}
+	query.normalize()
+
+	deliveries, total, err := h.webhookManager.ListDeliveries(id,
+		query.offset(), query.PageSize)
+	if err != nil {
+		respondWebhookError(ctx, err,
+			"error listing webhook deliveries")
+		return
+	}
+
+	res := api.ListWebhookDeliveriesResponse{
+		Deliveries: make([]api.WebhookDeliveryResponse, 0,
+			len(deliveries)),
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Total:    total,
+	}
+	for i := range deliveries {
+		res.Deliveries = append(res.Deliveries,
+			newWebhookDeliveryResponse(&deliveries[i]))
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+func newWebhookDeliveryResponse(
+	delivery *db.WebhookDelivery) api.WebhookDeliveryResponse {
+
+	res := api.WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		Event:          delivery.Event,
+		Status:         string(delivery.Status),
+		Attempts:       delivery.Attempts,
+		ResponseStatus: delivery.ResponseStatus,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		UpdatedAt:      delivery.UpdatedAt,
+	}
+	if delivery.Status == db.WebhookDeliveryPending {
+		nextAttemptAt := delivery.NextAttemptAt
+		res.NextAttemptAt = &nextAttemptAt
+	}
+	return res
+}
+
+// webhookIDParam parses the webhook ID from the request path. If the ID is
+// invalid, it responds with a bad request error and returns false.
+func webhookIDParam(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 0)
+	if err != nil {
+		ctx.JSON(
+			http.StatusBadRequest,
+			gin.H{"error": ErrInvalidRequest.Error()},
+		)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondWebhookError(ctx *gin.Context, err error, msg string) {
+	if errors.Is(err, webhook.ErrWebhookNotFound) {
+		ctx.JSON(
+			http.StatusNotFound,
+			gin.H{"error": err.Error()},
+		)
+		return
+	}
+
+	log.Warn().Err(err).Msg(msg)
+	ctx.JSON(
+		http.StatusInternalServerError,
+		gin.H{"error": ErrInternalServerError.Error()},
+	)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sunboyy/lettered/pkg/config"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/management"
+	"golang.org/x/term"
+)
+
+// errPasswordMismatch is returned when the password confirmation does not
+// match the password.
+var errPasswordMismatch = errors.New("passwords do not match")
+
+// passwd sets the administrator password of the management console. The
+// password is read from the terminal without echo and confirmed, or read from
+// the first line of the standard input if it is not a terminal.
+func passwd(cfg config.Config) error {
+	password, err := readNewPassword()
+	if err != nil {
+		return err
+	}
+
+	db, err := db.Open(filepath.Join(cfg.AppDataDir, "db.sqlite"))
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer db.Close()
+
+	auth, err := management.NewAuth(cfg.Management, db)
+	if err != nil {
+		return fmt.Errorf("new auth: %w", err)
+	}
+	if err := auth.SetPassword(password); err != nil {
+		return fmt.Errorf("set password: %w", err)
+	}
+
+	fmt.Println("password updated")
+	return nil
+}
+
+func readNewPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "New password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+
+	fmt.Fprint(os.Stderr, "Confirm password: ")
+	confirmation, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+
+	if string(password) != string(confirmation) {
+		return "", errPasswordMismatch
+	}
+	return string(password), nil
+}
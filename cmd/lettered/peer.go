@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/sunboyy/lettered/pkg/friend"
+	"github.com/sunboyy/lettered/pkg/letter"
 	"github.com/sunboyy/lettered/pkg/p2p"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -12,14 +14,16 @@ import (
 // PeerHandler contains a set of P2P handler functions.
 type PeerHandler struct {
 	friendManager *friend.Manager
+	letterManager *letter.Manager
 }
 
-func (h *PeerHandler) Ping(nodeID string, body []byte) (
+func (h *PeerHandler) Ping(_ context.Context, nodeID string, body []byte) (
 	protoreflect.ProtoMessage, error) {
 
 	var req p2p.PingRequest
 	if err := proto.Unmarshal(body, &req); err != nil {
-		return nil, fmt.Errorf("unmarshal req body: %w", err)
+		return nil, p2p.BadRequest(
+			fmt.Errorf("unmarshal req body: %w", err))
 	}
 
 	return &p2p.PingResponse{
@@ -27,12 +31,14 @@ func (h *PeerHandler) Ping(nodeID string, body []byte) (
 	}, nil
 }
 
-func (h *PeerHandler) ReceiveInvite(nodeID string, body []byte) (
+func (h *PeerHandler) ReceiveInvite(_ context.Context, nodeID string,
+	body []byte) (
 	protoreflect.ProtoMessage, error) {
 
 	var req p2p.FriendInviteRequest
 	if err := proto.Unmarshal(body, &req); err != nil {
-		return nil, fmt.Errorf("unmarshal req body: %w", err)
+		return nil, p2p.BadRequest(
+			fmt.Errorf("unmarshal req body: %w", err))
 	}
 
 	res, err := h.friendManager.ReceiveInvite(nodeID, &req)
@@ -41,3 +47,71 @@ func (h *PeerHandler) ReceiveInvite(nodeID string, body []byte) (
 	}
 	return res, nil
 }
+
+func (h *PeerHandler) ReceiveRemove(_ context.Context, nodeID string,
+	body []byte) (
+	protoreflect.ProtoMessage, error) {
+
+	var req p2p.FriendRemoveRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		return nil, p2p.BadRequest(
+			fmt.Errorf("unmarshal req body: %w", err))
+	}
+
+	res, err := h.friendManager.ReceiveRemove(nodeID, &req)
+	if err != nil {
+		return nil, fmt.Errorf("fm receive remove: %w", err)
+	}
+	return res, nil
+}
+
+func (h *PeerHandler) ReceiveLetter(_ context.Context, nodeID string,
+	body []byte) (
+	protoreflect.ProtoMessage, error) {
+
+	var req p2p.LetterSendRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		return nil, p2p.BadRequest(
+			fmt.Errorf("unmarshal req body: %w", err))
+	}
+
+	res, err := h.letterManager.ReceiveLetter(nodeID, &req)
+	if err != nil {
+		return nil, fmt.Errorf("lm receive letter: %w", err)
+	}
+	return res, nil
+}
+
+func (h *PeerHandler) ReceiveProfileUpdate(_ context.Context, nodeID string,
+	body []byte) (
+	protoreflect.ProtoMessage, error) {
+
+	var req p2p.ProfileUpdateRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		return nil, p2p.BadRequest(
+			fmt.Errorf("unmarshal req body: %w", err))
+	}
+
+	res, err := h.friendManager.ReceiveProfileUpdate(nodeID, &req)
+	if err != nil {
+		return nil, fmt.Errorf("fm receive profile update: %w", err)
+	}
+	return res, nil
+}
+
+func (h *PeerHandler) ReceiveHostnameUpdate(ctx context.Context,
+	nodeID string, body []byte) (
+	protoreflect.ProtoMessage, error) {
+
+	var req p2p.HostnameUpdateRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		return nil, p2p.BadRequest(
+			fmt.Errorf("unmarshal req body: %w", err))
+	}
+
+	res, err := h.friendManager.ReceiveHostnameUpdate(ctx, nodeID, &req)
+	if err != nil {
+		return nil, fmt.Errorf("fm receive hostname update: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/config"
+	"github.com/sunboyy/lettered/pkg/friend"
+	"github.com/sunboyy/lettered/pkg/management"
+	"github.com/sunboyy/lettered/pkg/p2p"
+)
+
+// liveKeys are the options that are applied to the running node when the
+// config is reloaded. Changes to the other options take effect on restart.
+var liveKeys = map[string]bool{
+	"LogLevel":                       true,
+	"Common.Alias":                   true,
+	"Common.Hostname":                true,
+	"P2P.RateLimit":                  true,
+	"Management.SessionTimeout":      true,
+	"Management.LoginAttempts":       true,
+	"Management.GlobalLoginAttempts": true,
+	"Management.LoginLockout":        true,
+	"Management.MaxLoginLockout":     true,
+}
+
+// reloader reads the config again and applies the changed live options to the
+// components of the running node.
+type reloader struct {
+	// mu serializes reloads and guards current.
+	mu sync.Mutex
+
+	opts *options
+
+	// current is the config in effect. Options that require a restart keep
+	// their values on start so that they are reported until the restart.
+	current config.Config
+
+	friendManager *friend.Manager
+	auth          *management.Auth
+	p2pServer     *p2p.Server
+}
+
+// Reload loads and validates the config, and then applies the changed live
+// options. It returns the keys of the applied options, the keys of the changed
+// options that require a restart and the keys of the changed options that are
+// overridden by the profile set at runtime. Nothing is applied if the config
+// is invalid.
+func (r *reloader) Reload() (applied []string, restartRequired []string,
+	overridden []string, err error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, err := r.opts.load()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := loaded.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+	cfg := loaded.Config
+
+	overriddenKeys := map[string]bool{}
+	for _, field := range r.friendManager.OverriddenConfig() {
+		overriddenKeys["Common."+field] = true
+	}
+
+	applied = []string{}
+	restartRequired = []string{}
+	overridden = []string{}
+	for _, key := range config.Keys() {
+		if config.Value(cfg, key) == config.Value(r.current, key) {
+			continue
+		}
+		switch {
+		case overriddenKeys[key]:
+			overridden = append(overridden, key)
+		case liveKeys[key]:
+			applied = append(applied, key)
+		default:
+			restartRequired = append(restartRequired, key)
+		}
+	}
+
+	r.current.LogLevel = cfg.LogLevel
+	r.current.Common = cfg.Common
+	r.current.P2P.RateLimit = cfg.P2P.RateLimit
+	r.current.Management.SessionTimeout = cfg.Management.SessionTimeout
+	r.current.Management.LoginAttempts = cfg.Management.LoginAttempts
+	r.current.Management.GlobalLoginAttempts =
+		cfg.Management.GlobalLoginAttempts
+	r.current.Management.LoginLockout = cfg.Management.LoginLockout
+	r.current.Management.MaxLoginLockout = cfg.Management.MaxLoginLockout
+
+	setLogLevel(r.current.LogLevel)
+	r.friendManager.UpdateConfig(r.current.Common)
+	r.auth.UpdateConfig(r.current.Management)
+	r.p2pServer.SetRateLimit(r.current.P2P.RateLimit)
+
+	return applied, restartRequired, overridden, nil
+}
+
+// reloadOnSignal reloads the config whenever the process receives SIGHUP until
+// the context is done.
+func (r *reloader) reloadOnSignal(ctx context.Context) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			r.reloadAndLog()
+		}
+	}
+}
+
+func (r *reloader) reloadAndLog() {
+	applied, restartRequired, overridden, err := r.Reload()
+	if err != nil {
+		log.Error().Err(err).Msg("error reloading config")
+		return
+	}
+	log.Info().Strs("applied", applied).
+		Strs("restartRequired", restartRequired).
+		Strs("overridden", overridden).
+		Msg("config reloaded")
+}
+
+// setLogLevel sets the global log level. The level must have been validated.
+func setLogLevel(level string) {
+	l, err := config.ParseLogLevel(level)
+	if err != nil {
+		l = zerolog.InfoLevel
+	}
+	zerolog.SetGlobalLevel(l)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version is the version of lettered. It is set at build time with
+// -ldflags "-X main.version=<version>".
+var version = ""
+
+func runVersion(opts *options, args []string) error {
+	flags := flag.NewFlagSet("version", flag.ContinueOnError)
+	if err := opts.parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	fmt.Printf("lettered %s %s %s/%s\n", buildVersion(), runtime.Version(),
+		runtime.GOOS, runtime.GOARCH)
+	return nil
+}
+
+// buildVersion returns the version set at build time, or the module version
+// if the binary is installed with go install.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok &&
+		info.Main.Version != "" && info.Main.Version != "(devel)" {
+
+		return info.Main.Version
+	}
+	return "devel"
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+func runIdentity(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	identifier, err := a.client.Identity(ctx)
+	if err != nil {
+		return err
+	}
+
+	res := api.IdentityResponse{Identifier: identifier}
+	return a.out.print(res, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, identifier)
+		return err
+	})
+}
+
+func runInvite(ctx context.Context, a *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	if err := a.client.SendInvite(ctx, args[0]); err != nil {
+		return err
+	}
+	return a.out.message("invite sent")
+}
+
+func runRequestsList(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("requests list", flag.ContinueOnError)
+	outgoing := flags.Bool("outgoing", false,
+		"list sent requests instead of received requests")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	var requests []api.FriendRequestResponse
+	var err error
+	if *outgoing {
+		requests, err = a.client.ListOutgoingRequests(ctx)
+	} else {
+		requests, err = a.client.ListIncomingRequests(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	res := api.ListFriendRequestsResponse{Requests: requests}
+	return a.out.print(res, func(io.Writer) error {
+		rows := make([][]string, 0, len(requests))
+		for _, r := range requests {
+			rows = append(rows, []string{r.NodeID, orDash(r.Alias),
+				r.Hostname, formatTime(r.UpdatedAt)})
+		}
+		return a.out.table([]string{"NODE ID", "ALIAS", "HOSTNAME",
+			"UPDATED"}, rows)
+	})
+}
+
+func runRequestsAccept(ctx context.Context, a *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	if err := a.client.AcceptRequest(ctx, args[0]); err != nil {
+		return err
+	}
+	return a.out.message("friend request accepted")
+}
+
+func runRequestsDecline(ctx context.Context, a *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	if err := a.client.DeclineRequest(ctx, args[0]); err != nil {
+		return err
+	}
+	return a.out.message("friend request declined")
+}
+
+func runFriendsList(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	friends, err := a.client.ListFriends(ctx)
+	if err != nil {
+		return err
+	}
+
+	res := api.ListFriendsResponse{Friends: friends}
+	return a.out.print(res, func(io.Writer) error {
+		rows := make([][]string, 0, len(friends))
+		for _, f := range friends {
+			rows = append(rows, []string{f.NodeID,
+				orDash(f.Nickname), orDash(f.Alias), f.Hostname,
+				formatTime(f.CreatedAt)})
+		}
+		return a.out.table([]string{"NODE ID", "NICKNAME", "ALIAS",
+			"HOSTNAME", "SINCE"}, rows)
+	})
+}
+
+func runFriendsRemove(ctx context.Context, a *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	if err := a.client.RemoveFriend(ctx, args[0]); err != nil {
+		return err
+	}
+	return a.out.message("friend removed")
+}
+
+// parseFlags parses the flags of a command and checks that the expected
+// number of positional arguments remains.
+func parseFlags(flags *flag.FlagSet, args []string, nArgs int) error {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil || flags.NArg() != nArgs {
+		return errUsage
+	}
+	return nil
+}
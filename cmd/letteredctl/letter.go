@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// outboxName is the box of the letters sent by the node.
+const outboxName = "outbox"
+
+func runLettersSend(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("letters send", flag.ContinueOnError)
+	subject := flags.String("subject", "", "subject of the letter")
+	body := flags.String("body", "", "body of the letter")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	if *body == "" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		*body = strings.TrimRight(string(b), "\n")
+	}
+
+	letter, err := a.client.SendLetter(ctx, api.SendLetterRequest{
+		NodeID:  flags.Arg(0),
+		Subject: *subject,
+		Body:    *body,
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.out.print(letter, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "letter %d %s\n", letter.ID,
+			letter.Status)
+		return err
+	})
+}
+
+func runLettersList(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("letters list", flag.ContinueOnError)
+	outbox := flags.Bool("outbox", false,
+		"list sent letters instead of received letters")
+	page := flags.Int("page", 1, "page number")
+	pageSize := flags.Int("page-size", 0, "number of letters per page")
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	var res *api.ListLettersResponse
+	var err error
+	if *outbox {
+		res, err = a.client.ListOutbox(ctx, *page, *pageSize)
+	} else {
+		res, err = a.client.ListInbox(ctx, *page, *pageSize)
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.out.print(res, func(w io.Writer) error {
+		rows := make([][]string, 0, len(res.Letters))
+		for _, l := range res.Letters {
+			rows = append(rows, []string{
+				strconv.FormatUint(uint64(l.ID), 10),
+				letterState(&l), l.NodeID, l.Subject,
+				formatTime(l.SentAt),
+			})
+		}
+		if err := a.out.table([]string{"ID", "STATE", "NODE ID",
+			"SUBJECT", "SENT"}, rows); err != nil {
+
+			return err
+		}
+		_, err := fmt.Fprintf(w, "page %d, %d letters in total\n",
+			res.Page, res.Total)
+		return err
+	})
+}
+
+func runLettersRead(ctx context.Context, a *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	id, err := strconv.ParseUint(args[0], 10, 0)
+	if err != nil {
+		return errUsage
+	}
+
+	letter, err := a.client.GetLetter(ctx, uint(id))
+	if err != nil {
+		return err
+	}
+	if !letter.IsRead {
+		letter, err = a.client.MarkLetterRead(ctx, uint(id))
+		if err != nil {
+			return err
+		}
+	}
+
+	return a.out.print(letter, func(w io.Writer) error {
+		direction := "From"
+		if letter.Box == outboxName {
+			direction = "To"
+		}
+		_, err := fmt.Fprintf(w,
+			"%s: %s\nSent: %s\nSubject: %s\n\n%s\n", direction,
+			letter.NodeID, formatTime(letter.SentAt),
+			letter.Subject, letter.Body)
+		return err
+	})
+}
+
+// letterState returns whether an inbox letter is read, or the delivery
+// status of an outbox letter.
+func letterState(l *api.LetterResponse) string {
+	if l.Box == outboxName {
+		return l.Status
+	}
+	if l.IsRead {
+		return "read"
+	}
+	return "unread"
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/sunboyy/lettered/pkg/management/client"
+)
+
+// defaultAddr is the address of the management server of a local node with
+// the default configuration.
+const defaultAddr = "http://localhost:11926"
+
+// errUsage is returned when a command is called with invalid arguments. The
+// usage of the command is printed instead of the error.
+var errUsage = errors.New("invalid usage")
+
+// command is a subcommand of letteredctl. The name may consist of several
+// words, e.g. "requests accept".
+type command struct {
+	name        string
+	args        string
+	description string
+	run         func(ctx context.Context, app *app, args []string) error
+}
+
+// synopsis returns the name of the command followed by its arguments.
+func (c *command) synopsis() string {
+	return strings.TrimSpace(c.name + " " + c.args)
+}
+
+var commands = []command{
+	{"login", "", "log in and cache the access token", runLogin},
+	{"logout", "", "log out and remove the cached access token", runLogout},
+	{"identity", "", "show the identifier of the node", runIdentity},
+	{"invite", "<identifier>", "send a friend invite", runInvite},
+	{"requests list", "[-outgoing]", "list friend requests",
+		runRequestsList},
+	{"requests accept", "<node-id>", "accept a friend request",
+		runRequestsAccept},
+	{"requests decline", "<node-id>", "decline a friend request",
+		runRequestsDecline},
+	{"friends list", "", "list friends", runFriendsList},
+	{"friends remove", "<node-id>", "remove a friend", runFriendsRemove},
+	{"letters send", "-subject <subject> [-body <body>] <node-id>",
+		"send a letter, reading the body from stdin if not given",
+		runLettersSend},
+	{"letters list", "[-outbox] [-page <n>] [-page-size <n>]",
+		"list received or sent letters", runLettersList},
+	{"letters read", "<id>", "show a letter and mark it as read",
+		runLettersRead},
+}
+
+// app contains the states shared by the commands.
+type app struct {
+	addr   string
+	client *client.Client
+	tokens *tokenCache
+	out    *printer
+
+	// tokenFromFlag is true if the token is given explicitly instead of
+	// being read from the cache.
+	tokenFromFlag bool
+}
+
+func main() {
+	flags := flag.NewFlagSet("letteredctl", flag.ContinueOnError)
+	flags.Usage = func() { usage(flags.Output()) }
+	addr := flags.String("addr", envOr("LETTERED_ADDR", defaultAddr),
+		"address of the management server")
+	token := flags.String("token", os.Getenv("LETTERED_TOKEN"),
+		"access token or API token, instead of the cached token")
+	jsonOutput := flags.Bool("json", false, "print output as JSON")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	cmd, args := findCommand(flags.Args())
+	if cmd == nil {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	tokens, err := loadTokenCache()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "letteredctl:", err)
+		os.Exit(1)
+	}
+
+	a := &app{
+		addr:          strings.TrimRight(*addr, "/"),
+		tokens:        tokens,
+		out:           &printer{w: os.Stdout, json: *jsonOutput},
+		tokenFromFlag: *token != "",
+	}
+	if !a.tokenFromFlag {
+		*token = tokens.get(a.addr)
+	}
+	a.client = client.NewClient(a.addr, *token)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := cmd.run(ctx, a, args); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, "usage: letteredctl",
+				cmd.synopsis())
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "letteredctl:", a.explain(err))
+		os.Exit(1)
+	}
+}
+
+// findCommand returns the command with the longest name matching the
+// beginning of the arguments, and the remaining arguments.
+func findCommand(args []string) (*command, []string) {
+	var found *command
+	var foundWords int
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(words) <= foundWords || len(words) > len(args) {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == commands[i].name {
+			found = &commands[i]
+			foundWords = len(words)
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	return found, args[foundWords:]
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: letteredctl [-addr <url>] [-token <token>] "+
+		"[-json] <command> [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\n", cmd.synopsis())
+		fmt.Fprintf(w, "\t%s\n", cmd.description)
+	}
+}
+
+// explain adds a hint to errors that the user can resolve.
+func (a *app) explain(err error) error {
+	var respErr *client.ResponseError
+	if errors.As(err, &respErr) &&
+		respErr.StatusCode == http.StatusUnauthorized &&
+		!a.tokenFromFlag {
+
+		return fmt.Errorf("%w (run letteredctl login)", err)
+	}
+	return err
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
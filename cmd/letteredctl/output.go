@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// timeFormat is the format of the times printed in tables.
+const timeFormat = "2006-01-02 15:04"
+
+// printer prints the results of the commands either as human-readable tables
+// or as JSON.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+// print prints v as JSON in JSON mode. Otherwise, it calls human to print v
+// in a human-readable form.
+func (p *printer) print(v interface{}, human func(w io.Writer) error) error {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("encode output: %w", err)
+		}
+		return nil
+	}
+	return human(p.w)
+}
+
+// table prints rows aligned in columns below the header.
+func (p *printer) table(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("print table: %w", err)
+	}
+	return nil
+}
+
+// message prints a confirmation of a command without a result. It prints an
+// empty object in JSON mode so that the output is always valid JSON.
+func (p *printer) message(format string, a ...interface{}) error {
+	return p.print(struct{}{}, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, format+"\n", a...)
+		return err
+	})
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format(timeFormat)
+}
+
+// orDash returns "-" for empty strings so that the columns stay aligned.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+func runLogin(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	res, err := a.client.Login(ctx, password)
+	if err != nil {
+		return err
+	}
+	if err := a.tokens.set(a.addr, res.AccessToken); err != nil {
+		return err
+	}
+
+	return a.out.print(res, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "logged in to %s until %s\n", a.addr,
+			formatTime(res.ExpiresAt))
+		return err
+	})
+}
+
+func runLogout(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	if err := a.client.Logout(ctx); err != nil {
+		return err
+	}
+	if !a.tokenFromFlag {
+		if err := a.tokens.remove(a.addr); err != nil {
+			return err
+		}
+	}
+	return a.out.message("logged out of %s", a.addr)
+}
+
+// readPassword reads the password from the terminal without echo, or from the
+// first line of the standard input if it is not a terminal.
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	return string(password), nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// tokenCache stores the access tokens of the login sessions by the address of
+// the management server. It is saved in the user config directory, readable
+// only by the user.
+type tokenCache struct {
+	path   string
+	Tokens map[string]string `json:"tokens"`
+}
+
+func loadTokenCache() (*tokenCache, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("user config dir: %w", err)
+	}
+
+	cache := &tokenCache{
+		path:   filepath.Join(dir, "lettered", "letteredctl.json"),
+		Tokens: map[string]string{},
+	}
+
+	b, err := os.ReadFile(cache.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read token cache: %w", err)
+	}
+	if err := json.Unmarshal(b, cache); err != nil {
+		return nil, fmt.Errorf("parse token cache %s: %w", cache.path,
+			err)
+	}
+	if cache.Tokens == nil {
+		cache.Tokens = map[string]string{}
+	}
+	return cache, nil
+}
+
+func (c *tokenCache) get(addr string) string {
+	return c.Tokens[addr]
+}
+
+func (c *tokenCache) set(addr string, token string) error {
+	c.Tokens[addr] = token
+	return c.save()
+}
+
+func (c *tokenCache) remove(addr string) error {
+	if _, ok := c.Tokens[addr]; !ok {
+		return nil
+	}
+	delete(c.Tokens, addr)
+	return c.save()
+}
+
+func (c *tokenCache) save() error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal token cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	if err := os.WriteFile(c.path, b, 0600); err != nil {
+		return fmt.Errorf("write token cache: %w", err)
+	}
+	return nil
+}
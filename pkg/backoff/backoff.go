@@ -0,0 +1,21 @@
+// Package backoff computes the waiting times between retries of failed
+// operations.
+package backoff
+
+import "time"
+
+// Exponential returns the duration to wait after the specified number of
+// failed attempts. The duration starts at the interval after the first
+// attempt, doubles after every further attempt and is capped at the maximum
+// interval.
+func Exponential(interval time.Duration, maxInterval time.Duration,
+	attempts int) time.Duration {
+
+	for i := 1; i < attempts && interval < maxInterval; i++ {
+		interval *= 2
+	}
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	return interval
+}
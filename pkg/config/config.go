@@ -1,57 +1,85 @@
 package config
 
 import (
-	"os"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"text/template"
 
 	"github.com/btcsuite/btcd/btcutil"
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
 	"github.com/sunboyy/lettered/pkg/common"
 	"github.com/sunboyy/lettered/pkg/management"
-	"gopkg.in/ini.v1"
+	"github.com/sunboyy/lettered/pkg/outbox"
+	"github.com/sunboyy/lettered/pkg/p2p"
+	"github.com/sunboyy/lettered/pkg/webhook"
 )
 
 var defaultAppDataDir = btcutil.AppDataDir("lettered", false)
 
+// ErrConfigNotFound is returned when the config file does not exist.
+var ErrConfigNotFound = errors.New("config file not found")
+
+var errInvalidLogLevel = errors.New("invalid log level")
+
+//go:embed default.ini
+var defaultFileTemplate string
+
+var defaultFile = template.Must(template.New("default.ini").
+	Parse(defaultFileTemplate))
+
 // Config contains all of the configuration options of the application.
 type Config struct {
 	AppDataDir string
 	P2PPort    int
+	LogLevel   string
+	P2P        p2p.Config
 	Common     common.Config
 	Management management.Config
+	Outbox     outbox.Config
+	Webhook    webhook.Config
 }
 
-// LoadConfig instantiates a Config struct and fill in the configuration options
-// from the config files in the following order:
-//  1. ./config.ini
-//
-// The configuration option that is not provided in any of the config files will
-// be set to default as described in the DefaultConfig function.
-func LoadConfig() Config {
-	cfg, err := ini.Load("config.ini")
-	if err != nil {
-		log.Fatal().Err(err).Msg("config: cannot load config file")
+// DefaultConfig returns all default values for the Config struct.
+func DefaultConfig() Config {
+	return Config{
+		AppDataDir: defaultAppDataDir,
+		P2PPort:    1926,
+		LogLevel:   "info",
+		P2P:        p2p.DefaultConfig(),
+		Common:     common.DefaultConfig(),
+		Management: management.DefaultConfig(),
+		Outbox:     outbox.DefaultConfig(),
+		Webhook:    webhook.DefaultConfig(),
 	}
+}
 
+// DefaultFile returns the content of a config file documenting every option.
+// AppDataDir is set to appDataDir, or to the default if it is empty. The other
+// options are commented out with their default values.
+func DefaultFile(appDataDir string) ([]byte, error) {
 	config := DefaultConfig()
-	if err := cfg.MapTo(&config); err != nil {
-		log.Fatal().Err(err).
-			Msg("config: cannot map config file to struct")
+	if appDataDir != "" {
+		config.AppDataDir = appDataDir
 	}
 
-	if err := os.MkdirAll(config.AppDataDir, 0700); err != nil {
-		log.Fatal().Err(err).
-			Msg("config: cannot ensure app data directory")
+	var buf bytes.Buffer
+	if err := defaultFile.Execute(&buf, config); err != nil {
+		return nil, fmt.Errorf("execute template: %w", err)
 	}
-
-	return config
+	return buf.Bytes(), nil
 }
 
-// DefaultConfig returns all default values for the Config struct.
-func DefaultConfig() Config {
-	return Config{
-		AppDataDir: defaultAppDataDir,
-		P2PPort:    1926,
-		Common:     common.DefaultConfig(),
-		Management: management.DefaultConfig(),
+// ParseLogLevel returns the zerolog level of the LogLevel option.
+func ParseLogLevel(level string) (zerolog.Level, error) {
+	l, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return zerolog.NoLevel, fmt.Errorf("parse log level: %w", err)
+	}
+	if l == zerolog.NoLevel {
+		return zerolog.NoLevel, fmt.Errorf("%w: %q", errInvalidLogLevel,
+			level)
 	}
+	return l, nil
 }
@@ -0,0 +1,292 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Source is where the value of a configuration option comes from.
+type Source string
+
+const (
+	// SourceDefault indicates that the option is not set by any layer.
+	SourceDefault Source = "default"
+
+	// SourceFile indicates that the option is set in the config file.
+	SourceFile Source = "file"
+
+	// SourceEnv indicates that the option is set by an environment
+	// variable.
+	SourceEnv Source = "env"
+
+	// SourceFlag indicates that the option is set by a command-line flag.
+	SourceFlag Source = "flag"
+)
+
+const (
+	// EnvPrefix is the prefix of the environment variables setting the
+	// options. The rest of the name is the key of the option in upper case
+	// with '.' replaced by '_', e.g. LETTERED_MANAGEMENT_PASSWORD.
+	EnvPrefix = "LETTERED_"
+
+	// EnvConfig is the environment variable specifying the path of the
+	// config file.
+	EnvConfig = EnvPrefix + "CONFIG"
+)
+
+// ErrUnknownOption is returned when a flag sets an option that does not
+// exist.
+var ErrUnknownOption = errors.New("unknown option")
+
+// secretKeys are the keys of the options that must not be displayed.
+var secretKeys = map[string]bool{
+	"Management.Password": true,
+}
+
+// LoadOptions defines the layers merged by Load on top of the defaults. Later
+// layers take precedence: the config file, the environment variables and the
+// flags.
+type LoadOptions struct {
+	// Path is the path of the config file. If it is empty, the path is
+	// taken from the EnvConfig environment variable, or the first existing
+	// file of SearchPaths is used. Only the defaults are used if there is
+	// no such file.
+	Path string
+
+	// Env contains the environment variables in the form "key=value", as
+	// returned by os.Environ.
+	Env []string
+
+	// Flags contains the values of the options set by command-line flags
+	// by key, e.g. "Management.Port".
+	Flags map[string]string
+}
+
+// Loaded is a configuration together with where it comes from.
+type Loaded struct {
+	Config Config
+
+	// Path is the path of the loaded config file. It is empty if no config
+	// file is loaded.
+	Path string
+
+	// Sources maps the key of each option to where its value comes from.
+	Sources map[string]Source
+
+	// problems are the values that cannot be parsed. The options keep
+	// their default values.
+	problems []Problem
+}
+
+// Validate checks the configuration like Config.Validate. It also reports the
+// values that cannot be parsed together with the other problems.
+func (l *Loaded) Validate() error {
+	var checked validator
+	l.Config.validate(&checked)
+
+	// Options that cannot be parsed keep their default values, which
+	// are not worth reporting.
+	unparsable := map[string]bool{}
+	for _, p := range l.problems {
+		unparsable[p.Key] = true
+	}
+
+	v := validator{problems: l.problems}
+	for _, p := range checked.problems {
+		if !unparsable[p.Key] {
+			v.problems = append(v.problems, p)
+		}
+	}
+	return v.err()
+}
+
+// Load merges the defaults with the layers in the options. The key of an
+// option is its field name in Config, prefixed by the name of the section
+// and a '.' if it is in a section, e.g. "AppDataDir" or "Management.Port".
+func Load(opts LoadOptions) (*Loaded, error) {
+	env := parseEnv(opts.Env)
+
+	path := opts.Path
+	if path == "" {
+		path = env[EnvConfig]
+	}
+	if path == "" {
+		path = FindFile()
+	}
+
+	file := ini.Empty()
+	if path != "" {
+		var err error
+		file, err = ini.Load(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrConfigNotFound,
+				path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("load config file: %w", err)
+		}
+	}
+
+	for key := range opts.Flags {
+		if !isKey(key) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownOption, key)
+		}
+	}
+
+	var v validator
+	sources := map[string]Source{}
+	for _, key := range Keys() {
+		section := file.Section(sectionOf(key))
+		name := nameOf(key)
+
+		sources[key] = SourceDefault
+		if section.HasKey(name) {
+			sources[key] = SourceFile
+		}
+		if value, ok := env[EnvName(key)]; ok {
+			section.Key(name).SetValue(value)
+			sources[key] = SourceEnv
+		}
+		if value, ok := opts.Flags[key]; ok {
+			section.Key(name).SetValue(value)
+			sources[key] = SourceFlag
+		}
+
+		if sources[key] != SourceDefault {
+			v.parsable(key, section.Key(name))
+		}
+	}
+
+	config := DefaultConfig()
+	if err := file.MapTo(&config); err != nil {
+		return nil, fmt.Errorf("map config to struct: %w", err)
+	}
+
+	return &Loaded{
+		Config:   config,
+		Path:     path,
+		Sources:  sources,
+		problems: v.problems,
+	}, nil
+}
+
+// SearchPaths returns the paths at which Load looks for the config file, in
+// order: the lettered directory in the user config directory (e.g.
+// $XDG_CONFIG_HOME), the default app data directory and, for compatibility,
+// the working directory.
+func SearchPaths() []string {
+	var paths []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "lettered",
+			"config.ini"))
+	}
+	return append(paths,
+		filepath.Join(defaultAppDataDir, "config.ini"),
+		"config.ini",
+	)
+}
+
+// DefaultPath returns the path at which a new config file is created when the
+// path is not specified.
+func DefaultPath() string {
+	return SearchPaths()[0]
+}
+
+// Keys returns the keys of all options in the order of Config.
+func Keys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type.Kind() != reflect.Struct {
+			keys = append(keys, field.Name)
+			continue
+		}
+		for j := 0; j < field.Type.NumField(); j++ {
+			keys = append(keys,
+				field.Name+"."+field.Type.Field(j).Name)
+		}
+	}
+	return keys
+}
+
+// Value returns the value of the option with the key formatted as in the
+// config file.
+func Value(config Config, key string) string {
+	v := reflect.ValueOf(config)
+	if section := sectionOf(key); section != "" {
+		v = v.FieldByName(section)
+	}
+	return fmt.Sprint(v.FieldByName(nameOf(key)).Interface())
+}
+
+// IsSecret reports whether the value of the option with the key must not be
+// displayed.
+func IsSecret(key string) bool {
+	return secretKeys[key]
+}
+
+// EnvName returns the name of the environment variable setting the option
+// with the key.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// FindFile returns the first existing file of SearchPaths, or an empty string
+// if there is none.
+func FindFile() string {
+	for _, path := range SearchPaths() {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// kindOf returns the kind of the option with the key.
+func kindOf(key string) reflect.Kind {
+	t := reflect.TypeOf(Config{})
+	if section := sectionOf(key); section != "" {
+		field, _ := t.FieldByName(section)
+		t = field.Type
+	}
+	field, _ := t.FieldByName(nameOf(key))
+	return field.Type.Kind()
+}
+
+func isKey(key string) bool {
+	for _, k := range Keys() {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func sectionOf(key string) string {
+	if i := strings.IndexByte(key, '.'); i >= 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+func nameOf(key string) string {
+	return key[strings.IndexByte(key, '.')+1:]
+}
+
+func parseEnv(environ []string) map[string]string {
+	env := map[string]string{}
+	for _, kv := range environ {
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return env
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/sunboyy/lettered/pkg/p2p"
+	"gopkg.in/ini.v1"
+)
+
+// Problem is an invalid configuration option.
+type Problem struct {
+	// Key is the key of the option, e.g. "Management.Port".
+	Key string
+
+	// Message describes what is wrong and how to fix it.
+	Message string
+}
+
+// ValidationError is returned when the configuration is invalid. It contains
+// all problems found so that they can be fixed at once.
+type ValidationError struct {
+	Problems []Problem
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		messages = append(messages, p.Key+": "+p.Message)
+	}
+	return "invalid config: " + strings.Join(messages, "; ")
+}
+
+// validator collects the problems of a configuration.
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) addf(key string, format string, a ...interface{}) {
+	v.problems = append(v.problems, Problem{
+		Key:     key,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// err returns a ValidationError with the problems, or nil if there is none.
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// Validate checks the configuration for problems that would otherwise make
+// the node fail or misbehave at runtime. It returns a ValidationError with all
+// problems found.
+func (c Config) Validate() error {
+	var v validator
+	c.validate(&v)
+	return v.err()
+}
+
+func (c Config) validate(v *validator) {
+	v.port("P2PPort", c.P2PPort)
+	v.port("Management.Port", c.Management.Port)
+	if c.P2PPort == c.Management.Port {
+		v.addf("Management.Port", "must differ from P2PPort (%d)",
+			c.P2PPort)
+	}
+
+	if _, err := ParseLogLevel(c.LogLevel); err != nil {
+		v.addf("LogLevel", "must be one of trace, debug, info, warn, "+
+			"error, fatal, panic or disabled, got %q", c.LogLevel)
+	}
+
+	v.alias("Common.Alias", c.Common.Alias)
+	v.hostname("Common.Hostname", c.Common.Hostname)
+
+	v.seconds("P2P.DialTimeout", c.P2P.DialTimeout)
+	v.seconds("P2P.HandshakeTimeout", c.P2P.HandshakeTimeout)
+	v.seconds("P2P.ReadTimeout", c.P2P.ReadTimeout)
+	v.seconds("P2P.WriteTimeout", c.P2P.WriteTimeout)
+	v.seconds("P2P.IdleTimeout", c.P2P.IdleTimeout)
+	if c.P2P.MaxMessageSize <= 0 {
+		v.addf("P2P.MaxMessageSize", "must be a positive number of "+
+			"bytes, got %d", c.P2P.MaxMessageSize)
+	}
+	v.nonNegative("P2P.RateLimit", c.P2P.RateLimit)
+
+	v.seconds("Management.SessionTimeout", c.Management.SessionTimeout)
+	v.nonNegative("Management.LoginAttempts", c.Management.LoginAttempts)
+	v.nonNegative("Management.GlobalLoginAttempts",
+		c.Management.GlobalLoginAttempts)
+	v.seconds("Management.LoginLockout", c.Management.LoginLockout)
+	v.seconds("Management.MaxLoginLockout", c.Management.MaxLoginLockout)
+	v.atLeast("Management.MaxLoginLockout", c.Management.MaxLoginLockout,
+		"Management.LoginLockout", c.Management.LoginLockout)
+
+	v.seconds("Outbox.RetryInterval", c.Outbox.RetryInterval)
+	v.seconds("Outbox.MaxRetryInterval", c.Outbox.MaxRetryInterval)
+	v.atLeast("Outbox.MaxRetryInterval", c.Outbox.MaxRetryInterval,
+		"Outbox.RetryInterval", c.Outbox.RetryInterval)
+	v.seconds("Outbox.MaxAge", c.Outbox.MaxAge)
+
+	v.seconds("Webhook.Timeout", c.Webhook.Timeout)
+	v.seconds("Webhook.RetryInterval", c.Webhook.RetryInterval)
+	v.seconds("Webhook.MaxRetryInterval", c.Webhook.MaxRetryInterval)
+	v.atLeast("Webhook.MaxRetryInterval", c.Webhook.MaxRetryInterval,
+		"Webhook.RetryInterval", c.Webhook.RetryInterval)
+	if c.Webhook.MaxAttempts <= 0 {
+		v.addf("Webhook.MaxAttempts", "must be a positive number, "+
+			"got %d", c.Webhook.MaxAttempts)
+	}
+	v.seconds("Webhook.LogRetention", c.Webhook.LogRetention)
+
+	v.writableDir("AppDataDir", c.AppDataDir)
+}
+
+// parsable checks that the value set to the option with the key can be parsed
+// into its type. Otherwise, the value is ignored when mapping to Config.
+func (v *validator) parsable(key string, k *ini.Key) {
+	var err error
+	var kind string
+	switch kindOf(key) {
+	case reflect.Int:
+		_, err = k.Int()
+		kind = "an integer"
+	case reflect.Bool:
+		_, err = k.Bool()
+		kind = "true or false"
+	default:
+		return
+	}
+	if err != nil {
+		v.addf(key, "must be %s, got %q", kind, k.String())
+	}
+}
+
+// seconds checks that the duration in seconds is positive.
+func (v *validator) seconds(key string, seconds int) {
+	if seconds <= 0 {
+		v.addf(key, "must be a positive number of seconds, got %d",
+			seconds)
+	}
+}
+
+// nonNegative checks that the value of an option that is disabled by zero is
+// not negative.
+func (v *validator) nonNegative(key string, value int) {
+	if value < 0 {
+		v.addf(key, "must be 0 (disabled) or a positive number, got %d",
+			value)
+	}
+}
+
+// atLeast checks that the maximum value of an option is not less than its
+// base value. Invalid base values are reported separately.
+func (v *validator) atLeast(key string, value int, baseKey string,
+	base int) {
+
+	if base > 0 && value > 0 && value < base {
+		v.addf(key, "must be at least %s (%d), got %d", baseKey, base,
+			value)
+	}
+}
+
+func (v *validator) port(key string, port int) {
+	if port < 1 || port > 65535 {
+		v.addf(key, "must be a port between 1 and 65535, got %d", port)
+	}
+}
+
+func (v *validator) alias(key string, alias string) {
+	if err := p2p.ValidateAlias(alias); err != nil {
+		v.addf(key, "%v", err)
+	}
+}
+
+func (v *validator) hostname(key string, hostname string) {
+	if err := p2p.ValidateHostname(hostname); err != nil {
+		v.addf(key, "%v", err)
+	}
+}
+
+// writableDir checks that the directory is writable, or that it can be
+// created if it does not exist.
+func (v *validator) writableDir(key string, dir string) {
+	if dir == "" {
+		v.addf(key, "must be set to a directory")
+		return
+	}
+
+	// Check the nearest existing directory since the missing ones are
+	// created on start.
+	existing := dir
+	for {
+		info, err := os.Stat(existing)
+		if err == nil {
+			if !info.IsDir() {
+				v.addf(key, "%s is not a directory", existing)
+				return
+			}
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			v.addf(key, "cannot access %s: %v", existing, err)
+			return
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+
+	f, err := os.CreateTemp(existing, ".lettered-")
+	if err != nil {
+		v.addf(key, "%s is not writable: %v", existing, err)
+		return
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIToken is a long-lived credential of the management console for
+// automation. Only the hash of the token is stored so that the token cannot be
+// recovered from the database.
+type APIToken struct {
+	gorm.Model
+
+	// Name is a label of the token given by the user.
+	Name string
+
+	// TokenHash is a SHA-256 hash of the token in hex.
+	TokenHash string `gorm:"uniqueIndex"`
+
+	// Scopes is a space-separated list of the scopes granted to the token.
+	Scopes string
+
+	// ExpiresAt is the time after which the token cannot be used. The
+	// token never expires if it is nil.
+	ExpiresAt *time.Time
+
+	// LastUsedAt is the time that the token was used most recently. It is
+	// nil if the token has never been used.
+	LastUsedAt *time.Time
+}
+
+// CreateAPIToken inserts an API token to the database.
+func (db *DB) CreateAPIToken(token *APIToken) error {
+	result := db.backend.Create(token)
+	return result.Error
+}
+
+// FindAPITokenByHash returns an API token with the specified token hash. An
+// error will not be returned if there is no record found the first return
+// value will be nil.
+func (db *DB) FindAPITokenByHash(tokenHash string) (*APIToken, error) {
+	var token APIToken
+	result := db.backend.Where("token_hash = ?", tokenHash).First(&token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return &token, nil
+}
+
+// ListAPITokens returns all API tokens ordered from the newest to the oldest.
+func (db *DB) ListAPITokens() ([]APIToken, error) {
+	var tokens []APIToken
+	result := db.backend.Order("created_at DESC").Find(&tokens)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return tokens, nil
+}
+
+// UpdateAPIToken updates an API token in the database.
+func (db *DB) UpdateAPIToken(token *APIToken) error {
+	result := db.backend.Save(token)
+	return result.Error
+}
+
+// DeleteAPIToken permanently deletes an API token with the specified ID. It
+// returns false if there is no such token.
+func (db *DB) DeleteAPIToken(id uint) (bool, error) {
+	result := db.backend.Unscoped().Delete(&APIToken{}, id)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+// Block is a data structure for peers that are not allowed to communicate
+// with the user. A peer is blocked either by its node ID or by its host.
+type Block struct {
+	gorm.Model
+
+	// NodeID is an identity of the blocked peer. It is empty if the block
+	// applies to the host only.
+	NodeID string
+
+	// Host is a host name or an IP address (without port) of the blocked
+	// peers. It is empty if the block applies to the node ID only.
+	Host string
+}
+
+// CreateBlock inserts a block to the database.
+func (db *DB) CreateBlock(nodeID string, host string) (*Block, error) {
+	block := Block{
+		NodeID: nodeID,
+		Host:   host,
+	}
+	result := db.backend.Create(&block)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &block, nil
+}
+
+// ListBlocks returns all blocks ordered from the newest to the oldest.
+func (db *DB) ListBlocks() ([]Block, error) {
+	var blocks []Block
+	result := db.backend.Order("created_at DESC").Find(&blocks)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return blocks, nil
+}
+
+// DeleteBlock permanently deletes a block with the specified ID. It returns
+// false if there is no such block.
+func (db *DB) DeleteBlock(id uint) (bool, error) {
+	result := db.backend.Unscoped().Delete(&Block{}, id)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// IsBlocked checks whether there is a block matching either the specified node
+// ID or the specified host. Empty arguments never match.
+func (db *DB) IsBlocked(nodeID string, host string) (bool, error) {
+	var count int64
+	result := db.backend.Model(&Block{}).
+		Where("node_id <> '' AND node_id = ?", nodeID).
+		Or("host <> '' AND host = ?", host).
+		Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
@@ -23,12 +23,36 @@ func Open(path string) (*DB, error) {
 		return nil, fmt.Errorf("open sqlite: %w", err)
 	}
 
+	if err := dedupeLetters(backend); err != nil {
+		return nil, fmt.Errorf("dedupe letters: %w", err)
+	}
+
 	if err := backend.AutoMigrate(
 		&FriendRequest{},
 		&Friend{},
+		&Letter{},
+		&OutboxMessage{},
+		&Block{},
+		&Setting{},
+		&Session{},
+		&APIToken{},
+		&Webhook{},
+		&WebhookDelivery{},
 	); err != nil {
 		return nil, fmt.Errorf("auto-migrate sqlite: %w", err)
 	}
 
 	return &DB{backend: backend}, nil
 }
+
+// Close closes the underlying database connection.
+func (db *DB) Close() error {
+	sqlDB, err := db.backend.DB()
+	if err != nil {
+		return fmt.Errorf("get sql db: %w", err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		return fmt.Errorf("close sql db: %w", err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -18,6 +19,10 @@ type FriendRequest struct {
 	// Hostname is a host endpoint of the peer that you can communicate to.
 	Hostname string
 
+	// Alias is the display name of the peer. It is only known for incoming
+	// requests.
+	Alias string
+
 	// IsInitiator is a boolean flag representing whether the friend request
 	// is initiated by own or by peer.
 	IsInitiator bool
@@ -41,13 +46,28 @@ func (db *DB) FindFriendRequest(nodeID string) (*FriendRequest,
 	return &friendRequest, nil
 }
 
+// ListFriendRequests returns outgoing friend requests if isInitiator is true,
+// or incoming friend requests otherwise, ordered from the newest to the oldest.
+func (db *DB) ListFriendRequests(isInitiator bool) ([]FriendRequest, error) {
+	var friendRequests []FriendRequest
+	result := db.backend.Where("is_initiator = ?", isInitiator).
+		Order("updated_at DESC").
+		Find(&friendRequests)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return friendRequests, nil
+}
+
 // CreateFriendRequest inserts a friend request to the database.
 func (db *DB) CreateFriendRequest(nodeID string, hostname string,
-	isInitiator bool) (*FriendRequest, error) {
+	alias string, isInitiator bool) (*FriendRequest, error) {
 
 	friendReq := FriendRequest{
 		NodeID:      nodeID,
 		Hostname:    hostname,
+		Alias:       alias,
 		IsInitiator: isInitiator,
 	}
 	result := db.backend.Create(&friendReq)
@@ -78,7 +98,25 @@ type Friend struct {
 	gorm.Model
 	NodeID   string
 	Hostname string
-	Alias    string
+
+	// Alias is the display name provided by the peer.
+	Alias string
+
+	// Nickname is the display name given to the peer by the user. It
+	// overrides Alias when it is not empty.
+	Nickname string
+
+	// Bio is a short description that the peer writes about itself.
+	Bio string
+
+	// Avatar is the URL of the profile picture of the peer.
+	Avatar string
+
+	// HostnameSignedAt is the time at which the peer signed the
+	// announcement of Hostname or sent it in its profile. It is zero if
+	// Hostname is obtained with the friend request. Older announcements are
+	// ignored.
+	HostnameSignedAt time.Time
 }
 
 // CreateFriend inserts a new friend data into the friend database using the
@@ -111,3 +149,42 @@ func (db *DB) FriendExists(nodeID string) (bool, error) {
 
 	return count > 0, nil
 }
+
+// FindFriend returns a friend with the specified node ID. An error will not be
+// returned if there is no record found but the first return value will be nil.
+func (db *DB) FindFriend(nodeID string) (*Friend, error) {
+	var friend Friend
+	result := db.backend.Where("node_id = ?", nodeID).First(&friend)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return &friend, nil
+}
+
+// ListFriends returns all friends ordered by the time that they become friends.
+func (db *DB) ListFriends() ([]Friend, error) {
+	var friends []Friend
+	result := db.backend.Order("created_at ASC").Find(&friends)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return friends, nil
+}
+
+// UpdateFriend updates a friend to the database by reading information in the
+// friend struct.
+func (db *DB) UpdateFriend(friend *Friend) error {
+	result := db.backend.Save(friend)
+	return result.Error
+}
+
+// DeleteFriend deletes a friend that matches the specified node ID.
+func (db *DB) DeleteFriend(nodeID string) error {
+	result := db.backend.Where("node_id = ?", nodeID).Delete(&Friend{})
+	return result.Error
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LetterBox specifies the mailbox in which the letter is located.
+type LetterBox string
+
+const (
+	// LetterBoxInbox is a mailbox of letters received from friends.
+	LetterBoxInbox LetterBox = "inbox"
+
+	// LetterBoxOutbox is a mailbox of letters sent to friends.
+	LetterBoxOutbox LetterBox = "outbox"
+)
+
+// LetterStatus specifies the delivery status of the letter.
+type LetterStatus string
+
+const (
+	// LetterStatusPending indicates that the letter is waiting in the
+	// outbox queue because the recipient is unreachable.
+	LetterStatusPending LetterStatus = "pending"
+
+	// LetterStatusDelivered indicates that the letter has been delivered
+	// to the recipient.
+	LetterStatusDelivered LetterStatus = "delivered"
+
+	// LetterStatusFailed indicates that the letter could not be delivered.
+	LetterStatusFailed LetterStatus = "failed"
+)
+
+// Letter is a data structure for letters that are sent to or received from
+// friends.
+type Letter struct {
+	gorm.Model
+
+	// LetterID is a random identifier generated by the sender. It is shared
+	// between the sender and the recipient of the letter. It is unique per
+	// box and node ID, including deleted letters.
+	LetterID string `gorm:"uniqueIndex:idx_letters_key,priority:3"`
+
+	// NodeID is an identity of the peer to whom the letter is sent or from
+	// whom the letter is received.
+	NodeID string `gorm:"uniqueIndex:idx_letters_key,priority:2"`
+
+	// Box specifies whether the letter is an incoming or outgoing letter.
+	Box LetterBox `gorm:"uniqueIndex:idx_letters_key,priority:1"`
+
+	Subject string
+	Body    string
+
+	// SentAt is the time that the sender composed the letter.
+	SentAt time.Time
+
+	// Status is the delivery status of the letter.
+	Status LetterStatus
+
+	// IsRead is a boolean flag representing whether the user has read the
+	// letter.
+	IsRead bool
+}
+
+// dedupeLetters removes the duplicate letters stored before letters are unique
+// per box, node ID and letter ID, so that the unique index can be created. The
+// first stored letter that has not been deleted is kept.
+func dedupeLetters(backend *gorm.DB) error {
+	migrator := backend.Migrator()
+	if !migrator.HasTable(&Letter{}) ||
+		migrator.HasIndex(&Letter{}, "idx_letters_key") {
+
+		return nil
+	}
+
+	result := backend.Exec(`DELETE FROM letters WHERE id NOT IN (
+		SELECT (
+			SELECT k.id FROM letters k
+			WHERE k.box = l.box AND k.node_id = l.node_id
+				AND k.letter_id = l.letter_id
+			ORDER BY k.deleted_at IS NOT NULL, k.id LIMIT 1
+		) FROM letters l GROUP BY l.box, l.node_id, l.letter_id
+	)`)
+	return result.Error
+}
+
+// CreateLetter inserts a letter to the database.
+func (db *DB) CreateLetter(letter *Letter) error {
+	result := db.backend.Create(letter)
+	return result.Error
+}
+
+// CreateLetterIfNotExists inserts a letter to the database unless there is a
+// letter with the same box, node ID and letter ID, including a deleted one. It
+// reports whether the letter is inserted.
+func (db *DB) CreateLetterIfNotExists(letter *Letter) (bool, error) {
+	result := db.backend.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(letter)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// LetterExists checks whether a letter in the specified box has been sent to
+// or received from the node ID with the given letter ID. Deleted letters are
+// included.
+func (db *DB) LetterExists(box LetterBox, nodeID string, letterID string) (
+	bool, error) {
+
+	var count int64
+	result := db.backend.Unscoped().Model(&Letter{}).
+		Where("box = ? AND node_id = ? AND letter_id = ?", box, nodeID,
+			letterID).
+		Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
+// FindLetterByLetterID returns a letter in the specified box that has been
+// sent to or received from the node ID with the given letter ID. An error will
+// not be returned if there is no record found but the first return value will
+// be nil.
+func (db *DB) FindLetterByLetterID(box LetterBox, nodeID string,
+	letterID string) (*Letter, error) {
+
+	var letter Letter
+	result := db.backend.
+		Where("box = ? AND node_id = ? AND letter_id = ?", box, nodeID,
+			letterID).
+		First(&letter)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return &letter, nil
+}
+
+// ListLetters returns letters in the specified box ordered from the newest to
+// the oldest, together with the total number of letters in the box. At most
+// limit letters are returned, starting from the offset.
+func (db *DB) ListLetters(box LetterBox, offset int, limit int) ([]Letter,
+	int64, error) {
+
+	var total int64
+	result := db.backend.Model(&Letter{}).Where("box = ?", box).
+		Count(&total)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	var letters []Letter
+	result = db.backend.Where("box = ?", box).
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&letters)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return letters, total, nil
+}
+
+// FindLetter returns a letter with the specified ID. An error will not be
+// returned if there is no record found but the first return value will be
+// nil.
+func (db *DB) FindLetter(id uint) (*Letter, error) {
+	var letter Letter
+	result := db.backend.First(&letter, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return &letter, nil
+}
+
+// UpdateLetter updates a letter to the database by reading information in the
+// letter struct.
+func (db *DB) UpdateLetter(letter *Letter) error {
+	result := db.backend.Save(letter)
+	return result.Error
+}
+
+// DeleteLetter deletes a letter with the specified ID.
+func (db *DB) DeleteLetter(id uint) error {
+	result := db.backend.Delete(&Letter{}, id)
+	return result.Error
+}
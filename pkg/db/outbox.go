@@ -0,0 +1,96 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OutboxMessage is a P2P request that has not been delivered to the peer yet.
+// It is kept in the database so that the delivery can be retried until the
+// peer becomes reachable.
+type OutboxMessage struct {
+	gorm.Model
+
+	// NodeID is an identity of the peer to whom the message is sent.
+	NodeID string
+
+	// Identifier is the peer identifier used for dialing the peer.
+	Identifier string
+
+	// Event is the P2P event of the request.
+	Event string
+
+	// Body is the protobuf-encoded request body.
+	Body []byte
+
+	// Attempts is the number of failed delivery attempts.
+	Attempts int
+
+	// NextAttemptAt is the earliest time that the next delivery attempt can
+	// be made.
+	NextAttemptAt time.Time
+
+	// LastError is the error message of the latest failed delivery attempt.
+	LastError string
+}
+
+// CreateOutboxMessage inserts an outbox message to the database.
+func (db *DB) CreateOutboxMessage(msg *OutboxMessage) error {
+	result := db.backend.Create(msg)
+	return result.Error
+}
+
+// ListDueOutboxMessages returns at most limit outbox messages whose next
+// delivery attempt is due at the specified time, the oldest first.
+func (db *DB) ListDueOutboxMessages(now time.Time, limit int) (
+	[]OutboxMessage, error) {
+
+	var msgs []OutboxMessage
+	result := db.backend.Where("next_attempt_at <= ?", now).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&msgs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return msgs, nil
+}
+
+// UpdateOutboxMessage updates an outbox message to the database by reading
+// information in the outbox message struct.
+func (db *DB) UpdateOutboxMessage(msg *OutboxMessage) error {
+	result := db.backend.Save(msg)
+	return result.Error
+}
+
+// DeleteOutboxMessage permanently deletes an outbox message with the specified
+// ID.
+func (db *DB) DeleteOutboxMessage(id uint) error {
+	result := db.backend.Unscoped().Delete(&OutboxMessage{}, id)
+	return result.Error
+}
+
+// DeleteOutboxMessages permanently deletes all outbox messages of the
+// specified event that are sent to the node ID.
+func (db *DB) DeleteOutboxMessages(nodeID string, event string) error {
+	result := db.backend.Unscoped().
+		Where("node_id = ? AND event = ?", nodeID, event).
+		Delete(&OutboxMessage{})
+	return result.Error
+}
+
+// RedirectOutboxMessages changes the identifier of all outbox messages sent to
+// the node ID and makes them due at the specified time.
+func (db *DB) RedirectOutboxMessages(nodeID string, identifier string,
+	now time.Time) error {
+
+	result := db.backend.Model(&OutboxMessage{}).
+		Where("node_id = ?", nodeID).
+		Updates(map[string]interface{}{
+			"identifier":      identifier,
+			"next_attempt_at": now,
+		})
+	return result.Error
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"time"
+)
+
+// Session is a logged-in session of the management console. Only the hash of
+// the access token is stored so that the access token cannot be recovered
+// from the database.
+type Session struct {
+	// ID is a public identity of the session used for revoking it.
+	ID string `gorm:"primaryKey"`
+
+	// TokenHash is a SHA-256 hash of the access token in hex.
+	TokenHash string `gorm:"uniqueIndex"`
+
+	// ClientIP is the IP address of the client that used the session most
+	// recently.
+	ClientIP string
+
+	CreatedAt time.Time
+
+	// LastUsedAt is the time that the session was used most recently.
+	LastUsedAt time.Time
+
+	// ExpiresAt is the time after which the session cannot be used.
+	ExpiresAt time.Time
+}
+
+// CreateSession inserts a session to the database.
+func (db *DB) CreateSession(session *Session) error {
+	result := db.backend.Create(session)
+	return result.Error
+}
+
+// ListSessions returns all sessions that have not expired at the specified
+// time.
+func (db *DB) ListSessions(now time.Time) ([]Session, error) {
+	var sessions []Session
+	result := db.backend.Where("expires_at > ?", now).Find(&sessions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return sessions, nil
+}
+
+// UpdateSession updates a session in the database.
+func (db *DB) UpdateSession(session *Session) error {
+	result := db.backend.Save(session)
+	return result.Error
+}
+
+// DeleteSession deletes a session with the specified ID.
+func (db *DB) DeleteSession(id string) error {
+	result := db.backend.Delete(&Session{}, "id = ?", id)
+	return result.Error
+}
+
+// DeleteExpiredSessions deletes all sessions that have expired at the
+// specified time.
+func (db *DB) DeleteExpiredSessions(now time.Time) error {
+	result := db.backend.Where("expires_at <= ?", now).Delete(&Session{})
+	return result.Error
+}
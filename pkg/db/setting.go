@@ -0,0 +1,72 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Setting is a key-value data structure for application states that are
+// managed at runtime rather than in the config file.
+type Setting struct {
+	Key       string `gorm:"primaryKey"`
+	Value     string
+	UpdatedAt time.Time
+}
+
+// GetSetting returns the value of the setting with the specified key. The
+// second return value is false if the setting does not exist.
+func (db *DB) GetSetting(key string) (string, bool, error) {
+	var setting Setting
+	result := db.backend.Where("key = ?", key).First(&setting)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return "", false, nil
+		}
+		return "", false, result.Error
+	}
+
+	return setting.Value, true, nil
+}
+
+// SetSetting creates or updates the setting with the specified key.
+func (db *DB) SetSetting(key string, value string) error {
+	result := db.backend.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&Setting{
+		Key:   key,
+		Value: value,
+	})
+	return result.Error
+}
+
+// SetSettings creates or updates the settings with the specified keys and
+// values in a single transaction, so that either all or none of them are
+// stored. The settings with empty values are deleted.
+func (db *DB) SetSettings(values map[string]string) error {
+	return db.backend.Transaction(func(tx *gorm.DB) error {
+		for key, value := range values {
+			if value == "" {
+				result := tx.Where("key = ?", key).
+					Delete(&Setting{})
+				if result.Error != nil {
+					return result.Error
+				}
+				continue
+			}
+
+			result := tx.Clauses(clause.OnConflict{
+				UpdateAll: true,
+			}).Create(&Setting{
+				Key:   key,
+				Value: value,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+}
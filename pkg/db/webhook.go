@@ -0,0 +1,197 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook is an HTTP endpoint registered by the user to receive events of the
+// node.
+type Webhook struct {
+	gorm.Model
+
+	// URL is the endpoint to which events are posted.
+	URL string
+
+	// Secret is the key for signing the payloads so that the receiver can
+	// verify that they are sent by the node.
+	Secret string
+
+	// Events is a space-separated list of the event types posted to the
+	// webhook.
+	Events string
+}
+
+// WebhookDeliveryStatus specifies the state of a webhook delivery.
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending indicates that the delivery is waiting for
+	// the next attempt.
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending"
+
+	// WebhookDeliveryDelivered indicates that the receiver has accepted
+	// the delivery.
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+
+	// WebhookDeliveryFailed indicates that the delivery has been given up.
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an event posted to a webhook. Deliveries are kept after
+// they finish as a log of the webhook.
+type WebhookDelivery struct {
+	gorm.Model
+
+	// WebhookID is the ID of the webhook to which the event is posted.
+	WebhookID uint `gorm:"index"`
+
+	// Event is the type of the event.
+	Event string
+
+	// Payload is the JSON request body.
+	Payload []byte
+
+	// Status is the state of the delivery.
+	Status WebhookDeliveryStatus `gorm:"index"`
+
+	// Attempts is the number of delivery attempts.
+	Attempts int
+
+	// NextAttemptAt is the earliest time that the next delivery attempt can
+	// be made.
+	NextAttemptAt time.Time
+
+	// ResponseStatus is the HTTP status code of the latest attempt. It is
+	// zero if no response has been received.
+	ResponseStatus int
+
+	// LastError is the error message of the latest failed attempt.
+	LastError string
+}
+
+// CreateWebhook inserts a webhook to the database.
+func (db *DB) CreateWebhook(webhook *Webhook) error {
+	result := db.backend.Create(webhook)
+	return result.Error
+}
+
+// FindWebhook returns a webhook with the specified ID. An error will not be
+// returned if there is no record found but the first return value will be
+// nil.
+func (db *DB) FindWebhook(id uint) (*Webhook, error) {
+	var webhook Webhook
+	result := db.backend.First(&webhook, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+
+	return &webhook, nil
+}
+
+// ListWebhooks returns all webhooks ordered from the oldest to the newest.
+func (db *DB) ListWebhooks() ([]Webhook, error) {
+	var webhooks []Webhook
+	result := db.backend.Order("created_at ASC").Find(&webhooks)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook permanently deletes a webhook with the specified ID together
+// with its deliveries. It returns false if there is no such webhook.
+func (db *DB) DeleteWebhook(id uint) (bool, error) {
+	var deleted bool
+	err := db.backend.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Delete(&Webhook{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected > 0
+
+		result = tx.Unscoped().Where("webhook_id = ?", id).
+			Delete(&WebhookDelivery{})
+		return result.Error
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return deleted, nil
+}
+
+// CreateWebhookDelivery inserts a webhook delivery to the database.
+func (db *DB) CreateWebhookDelivery(delivery *WebhookDelivery) error {
+	result := db.backend.Create(delivery)
+	return result.Error
+}
+
+// ListDueWebhookDeliveries returns at most limit pending webhook deliveries
+// whose next attempt is due at the specified time, the oldest first.
+func (db *DB) ListDueWebhookDeliveries(now time.Time, limit int) (
+	[]WebhookDelivery, error) {
+
+	var deliveries []WebhookDelivery
+	result := db.backend.
+		Where("status = ? AND next_attempt_at <= ?",
+			WebhookDeliveryPending, now).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&deliveries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return deliveries, nil
+}
+
+// ListWebhookDeliveries returns deliveries of the webhook ordered from the
+// newest to the oldest, together with the total number of deliveries of the
+// webhook. At most limit deliveries are returned, starting from the offset.
+func (db *DB) ListWebhookDeliveries(webhookID uint, offset int, limit int) (
+	[]WebhookDelivery, int64, error) {
+
+	var total int64
+	result := db.backend.Model(&WebhookDelivery{}).
+		Where("webhook_id = ?", webhookID).
+		Count(&total)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	var deliveries []WebhookDelivery
+	result = db.backend.Where("webhook_id = ?", webhookID).
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&deliveries)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return deliveries, total, nil
+}
+
+// UpdateWebhookDelivery updates a webhook delivery to the database by reading
+// information in the webhook delivery struct.
+func (db *DB) UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	result := db.backend.Save(delivery)
+	return result.Error
+}
+
+// DeleteFinishedWebhookDeliveries permanently deletes delivered and failed
+// webhook deliveries that were created before the specified time.
+func (db *DB) DeleteFinishedWebhookDeliveries(before time.Time) error {
+	result := db.backend.Unscoped().
+		Where("status <> ? AND created_at < ?", WebhookDeliveryPending,
+			before).
+		Delete(&WebhookDelivery{})
+	return result.Error
+}
@@ -0,0 +1,140 @@
+package event
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// subscriptionBuffer is the number of events that a subscriber can fall behind
+// before it is closed.
+const subscriptionBuffer = 64
+
+// Hook is a function called with every published event before Publish
+// returns.
+type Hook func(e Event)
+
+// Bus delivers published events to all subscribers. Publishing never blocks on
+// subscribers: subscribers that fall too far behind are closed and have to
+// subscribe again. Hooks receive every event since they are called by the
+// publisher.
+type Bus struct {
+	mu          sync.Mutex
+	nextID      uint64
+	subscribers map[*Subscription]struct{}
+	hooks       []Hook
+	closed      bool
+}
+
+// NewBus is a constructor of Bus.
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Publish sends an event of the specified type and data to all subscribers and
+// calls the hooks with it. It does nothing after the bus is closed.
+func (b *Bus) Publish(eventType Type, data interface{}) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+
+	b.nextID++
+	e := Event{
+		ID:   b.nextID,
+		Type: eventType,
+		Time: time.Now(),
+		Data: data,
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- e:
+		default:
+			log.Warn().Msgf("event: closing slow subscriber "+
+				"after event %d", e.ID)
+			b.unsubscribe(sub)
+		}
+	}
+	hooks := b.hooks
+	b.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook(e)
+	}
+}
+
+// AddHook registers a function to be called with every event published from
+// now on. Unlike a subscription, a hook never misses events, but it holds up
+// the publisher, so it should return quickly.
+func (b *Bus) AddHook(hook Hook) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.hooks = append(b.hooks, hook)
+}
+
+// Subscribe returns a new subscription receiving events published from now
+// on. The subscription must be closed when it is no longer used.
+func (b *Bus) Subscribe() *Subscription {
+	sub := &Subscription{
+		bus: b,
+		ch:  make(chan Event, subscriptionBuffer),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.ch)
+		return sub
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Close closes all subscriptions and discards events published afterwards.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subscribers {
+		b.unsubscribe(sub)
+	}
+}
+
+// unsubscribe removes the subscriber and closes its channel. The caller must
+// hold mu.
+func (b *Bus) unsubscribe(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.ch)
+}
+
+// Subscription receives events from a bus.
+type Subscription struct {
+	bus *Bus
+	ch  chan Event
+}
+
+// Events returns a channel of the events. The channel is closed when the
+// subscription or the bus is closed, or when the subscriber falls too far
+// behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close stops receiving events.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.unsubscribe(s)
+}
@@ -0,0 +1,73 @@
+package event
+
+import "time"
+
+// Type identifies the kind of an event.
+type Type string
+
+const (
+	// TypeFriendRequestReceived is published when a peer sends a friend
+	// request to the user. The data is a Friend.
+	TypeFriendRequestReceived Type = "friend.request_received"
+
+	// TypeFriendAccepted is published when a friend request is accepted by
+	// either side and the peer becomes a friend. The data is a Friend.
+	TypeFriendAccepted Type = "friend.accepted"
+
+	// TypeLetterReceived is published when a friend sends a letter to the
+	// user. The data is a Letter.
+	TypeLetterReceived Type = "letter.received"
+
+	// TypeDeliveryFailed is published when a queued letter cannot be
+	// delivered to the friend. The data is a Letter.
+	TypeDeliveryFailed Type = "letter.delivery_failed"
+
+	// TypePeerOnline is published when a friend becomes reachable. The data
+	// is a Peer.
+	TypePeerOnline Type = "peer.online"
+
+	// TypePeerOffline is published when a friend becomes unreachable. The
+	// data is a Peer.
+	TypePeerOffline Type = "peer.offline"
+)
+
+// Types are all types of events published by the system.
+var Types = []Type{
+	TypeFriendRequestReceived,
+	TypeFriendAccepted,
+	TypeLetterReceived,
+	TypeDeliveryFailed,
+	TypePeerOnline,
+	TypePeerOffline,
+}
+
+// Event is a notification of something that has happened in the system.
+type Event struct {
+	// ID is a sequence number of the event that increases with every
+	// published event.
+	ID   uint64      `json:"id"`
+	Type Type        `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// Friend is the data of friend events.
+type Friend struct {
+	NodeID   string `json:"nodeId"`
+	Hostname string `json:"hostname"`
+	Alias    string `json:"alias"`
+}
+
+// Letter is the data of letter events.
+type Letter struct {
+	ID       uint      `json:"id"`
+	LetterID string    `json:"letterId"`
+	NodeID   string    `json:"nodeId"`
+	Subject  string    `json:"subject"`
+	SentAt   time.Time `json:"sentAt"`
+}
+
+// Peer is the data of peer presence events.
+type Peer struct {
+	NodeID string `json:"nodeId"`
+}
@@ -0,0 +1,93 @@
+package friend
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/sunboyy/lettered/pkg/db"
+)
+
+var (
+	// ErrEmptyBlock is returned when blocking without specifying either
+	// a node ID or a host.
+	ErrEmptyBlock = errors.New("node id or host is required")
+
+	// ErrBlockNotFound is returned when there is no block with the
+	// specified ID.
+	ErrBlockNotFound = errors.New("block not found")
+)
+
+// ListBlocks returns all blocked node IDs and hosts.
+func (m *Manager) ListBlocks() ([]db.Block, error) {
+	blocks, err := m.db.ListBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("list blocks: %w", err)
+	}
+	return blocks, nil
+}
+
+// Block rejects further communication from the peer with the specified node
+// ID, or from any peer at the specified host. Pending incoming friend requests
+// from the blocked peers are deleted.
+func (m *Manager) Block(nodeID string, host string) (*db.Block, error) {
+	if nodeID == "" && host == "" {
+		return nil, ErrEmptyBlock
+	}
+
+	block, err := m.db.CreateBlock(nodeID, host)
+	if err != nil {
+		return nil, fmt.Errorf("create block: %w", err)
+	}
+
+	friendReqs, err := m.db.ListFriendRequests(false)
+	if err != nil {
+		return nil, fmt.Errorf("list incoming friend reqs: %w", err)
+	}
+	for _, friendReq := range friendReqs {
+		reqNodeID := friendReq.NodeID
+		if (nodeID == "" || reqNodeID != nodeID) &&
+			(host == "" || hostOf(friendReq.Hostname) != host) {
+
+			continue
+		}
+
+		if err := m.db.DeleteFriendRequest(reqNodeID); err != nil {
+			return nil, fmt.Errorf("delete friend req %s: %w",
+				reqNodeID, err)
+		}
+	}
+
+	return block, nil
+}
+
+// Unblock deletes the block with the specified ID.
+func (m *Manager) Unblock(id uint) error {
+	deleted, err := m.db.DeleteBlock(id)
+	if err != nil {
+		return fmt.Errorf("delete block %d: %w", id, err)
+	}
+	if !deleted {
+		return ErrBlockNotFound
+	}
+	return nil
+}
+
+// AllowPeer returns false if the peer with the specified node ID or host is
+// blocked. It implements p2p.GuardFunc.
+func (m *Manager) AllowPeer(nodeID string, host string) (bool, error) {
+	blocked, err := m.db.IsBlocked(nodeID, host)
+	if err != nil {
+		return false, fmt.Errorf("check block %s: %w", nodeID, err)
+	}
+	return !blocked, nil
+}
+
+// hostOf returns the host part of the hostname without port.
+func hostOf(hostname string) string {
+	host, _, err := net.SplitHostPort(hostname)
+	if err != nil {
+		return hostname
+	}
+	return host
+}
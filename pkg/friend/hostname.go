@@ -0,0 +1,159 @@
+package friend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/p2p"
+)
+
+// maxClockSkew is the maximum duration by which the signing time of a
+// hostname announcement may be ahead of the local clock.
+const maxClockSkew = 5 * time.Minute
+
+// errAnnouncedInFuture is an error indicating that the time at which a
+// hostname is announced is too far ahead of the local clock.
+var errAnnouncedInFuture = errors.New("hostname is announced in the future")
+
+// AnnounceHostname queues a signed announcement of the hostname of the user to
+// all friends, replacing the previously queued announcement, if any. It is
+// called on every start so that friends learn a hostname changed while the
+// node is stopped, and friends that missed an earlier announcement, e.g.
+// because it expired in the outbox, receive it again. Friends known not to
+// support HOSTNAME_UPDATE are skipped, and the outbox checks the others before
+// delivery.
+func (m *Manager) AnnounceHostname() error {
+	m.announceMu.Lock()
+	defer m.announceMu.Unlock()
+
+	hostname := m.Profile().Hostname
+	req, err := m.p2pClient.NewHostnameUpdate(hostname)
+	if err != nil {
+		return fmt.Errorf("new hostname update: %w", err)
+	}
+
+	friends, err := m.db.ListFriends()
+	if err != nil {
+		return fmt.Errorf("list friends: %w", err)
+	}
+	queued := 0
+	for i := range friends {
+		nodeID := friends[i].NodeID
+		if !m.maySupport(nodeID, p2p.EventHostnameUpdate) {
+			continue
+		}
+		if err := m.outbox.Cancel(nodeID,
+			p2p.EventHostnameUpdate); err != nil {
+
+			return fmt.Errorf("cancel hostname update %s: %w",
+				nodeID, err)
+		}
+		identifier := p2p.CreateIdentifier(nodeID, friends[i].Hostname)
+		if err := m.outbox.Enqueue(identifier, p2p.EventHostnameUpdate,
+			req); err != nil {
+
+			return fmt.Errorf("enqueue hostname update %s: %w",
+				nodeID, err)
+		}
+		queued++
+	}
+
+	log.Info().Msgf("announcing hostname %s to %d friends", hostname,
+		queued)
+	return nil
+}
+
+// ReceiveHostnameUpdate processes the announcement of a new hostname of a
+// friend. The announcement must be signed by the friend with the key that it
+// authenticates the connection with, so the context must be the one of the
+// request. The undelivered messages to the friend are sent to the new
+// hostname.
+func (m *Manager) ReceiveHostnameUpdate(ctx context.Context, nodeID string,
+	req *p2p.HostnameUpdateRequest) (*p2p.HostnameUpdateResponse, error) {
+
+	if err := validateHostname(req.Hostname); err != nil {
+		return nil, p2p.BadRequest(err)
+	}
+	if err := p2p.VerifyHostnameUpdate(ctx, nodeID, req); err != nil {
+		return nil, p2p.BadRequest(
+			fmt.Errorf("verify hostname update: %w", err))
+	}
+	signedAt := time.UnixMilli(req.Timestamp)
+	if err := m.checkFriendHostname(nodeID, req.Hostname,
+		signedAt); err != nil {
+
+		return nil, err
+	}
+
+	friend, err := m.db.FindFriend(nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("find friend %s: %w", nodeID, err)
+	}
+	if friend == nil {
+		return nil, p2p.ErrNotFriend
+	}
+
+	if err := m.updateFriendHostname(friend, req.Hostname,
+		signedAt); err != nil {
+
+		return nil, err
+	}
+	return &p2p.HostnameUpdateResponse{}, nil
+}
+
+// checkFriendHostname checks a valid hostname that the peer with the node ID
+// announced at the time.
+func (m *Manager) checkFriendHostname(nodeID string, hostname string,
+	announcedAt time.Time) error {
+
+	if time.Until(announcedAt) > maxClockSkew {
+		return p2p.BadRequest(errAnnouncedInFuture)
+	}
+
+	// Reject the announcement if it advertises a blocked host.
+	allowed, err := m.AllowPeer(nodeID, hostOf(hostname))
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return p2p.ErrBlocked
+	}
+	return nil
+}
+
+// updateFriendHostname stores the friend with the hostname that it announced
+// at the time, unless it has announced another hostname later. The
+// undelivered messages to the friend are sent to the new hostname.
+func (m *Manager) updateFriendHostname(friend *db.Friend, hostname string,
+	announcedAt time.Time) error {
+
+	nodeID := friend.NodeID
+	moved := false
+	if announcedAt.Before(friend.HostnameSignedAt) {
+		log.Debug().Msgf("ignoring outdated hostname %s of %s",
+			hostname, nodeID)
+	} else {
+		moved = friend.Hostname != hostname
+		if moved {
+			log.Info().Msgf("friend %s moved from %s to %s", nodeID,
+				friend.Hostname, hostname)
+		}
+		friend.Hostname = hostname
+		friend.HostnameSignedAt = announcedAt
+	}
+	if err := m.db.UpdateFriend(friend); err != nil {
+		return fmt.Errorf("update friend %s: %w", nodeID, err)
+	}
+
+	if moved {
+		identifier := p2p.CreateIdentifier(nodeID, hostname)
+		if err := m.outbox.Redirect(nodeID, identifier); err != nil {
+			return fmt.Errorf("redirect outbox %s: %w", nodeID, err)
+		}
+	}
+	return nil
+}
@@ -1,12 +1,18 @@
 package friend
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
 
+	"github.com/rs/zerolog/log"
 	"github.com/sunboyy/lettered/pkg/common"
 	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/event"
+	"github.com/sunboyy/lettered/pkg/outbox"
 	"github.com/sunboyy/lettered/pkg/p2p"
+	"google.golang.org/protobuf/proto"
 )
 
 var (
@@ -20,32 +26,92 @@ var (
 	// ErrInvalidIdentifier is an error indicating that the identifier
 	// cannot be extracted because of an unexpected pattern.
 	ErrInvalidIdentifier = errors.New("invalid identifier")
+
+	// ErrRequestNotFound is returned when there is no pending friend
+	// request of the specified direction with the peer.
+	ErrRequestNotFound = errors.New("friend request not found")
+
+	// ErrFriendNotFound is returned when the peer with the specified node
+	// ID is not a friend of the user.
+	ErrFriendNotFound = errors.New("friend not found")
+
+	// ErrInvalidProfile is returned when updating the profile with an
+	// invalid value.
+	ErrInvalidProfile = errors.New("invalid profile")
 )
 
 // Manager contains a set of functionalities managing user's friends.
 type Manager struct {
+	// configMu guards commonConfig and profileSettings.
+	configMu     sync.RWMutex
 	commonConfig common.Config
-	db           *db.DB
-	p2pClient    *p2p.Client
-	nodeID       string
+
+	// profileSettings are the profile fields set at runtime by setting
+	// key. They take precedence over commonConfig.
+	profileSettings map[string]string
+
+	// announceMu serializes hostname announcements.
+	announceMu sync.Mutex
+
+	db        *db.DB
+	p2pClient *p2p.Client
+	outbox    *outbox.Worker
+	events    *event.Bus
+	nodeID    string
+
+	// presenceMu guards online.
+	presenceMu sync.Mutex
+
+	// online stores the last known reachability of peers by node ID.
+	online map[string]bool
 }
 
 // NewManager is a constructor of Manager.
 func NewManager(commonConfig common.Config, db *db.DB,
-	p2pClient *p2p.Client, nodeID string) *Manager {
+	p2pClient *p2p.Client, outbox *outbox.Worker, events *event.Bus,
+	nodeID string) *Manager {
 
 	return &Manager{
-		commonConfig: commonConfig,
-		db:           db,
-		p2pClient:    p2pClient,
-		nodeID:       nodeID,
+		commonConfig:    commonConfig,
+		profileSettings: map[string]string{},
+		db:              db,
+		p2pClient:       p2pClient,
+		outbox:          outbox,
+		events:          events,
+		nodeID:          nodeID,
+		online:          map[string]bool{},
 	}
 }
 
+// CommonConfig returns the application-wide configuration that the manager
+// currently uses. The alias and the hostname set in the profile take
+// precedence over the config.
+func (m *Manager) CommonConfig() common.Config {
+	profile := m.Profile()
+	return common.Config{
+		Alias:    profile.Alias,
+		Hostname: profile.Hostname,
+	}
+}
+
+// UpdateConfig replaces the application-wide configuration. The new alias and
+// hostname are sent in subsequent messages to peers, and friends are notified
+// if the profile changes. The values set in the profile still take
+// precedence.
+func (m *Manager) UpdateConfig(commonConfig common.Config) {
+	m.configMu.Lock()
+	before := m.profile()
+	m.commonConfig = commonConfig
+	after := m.profile()
+	m.configMu.Unlock()
+
+	m.publishProfile(before, after)
+}
+
 // SendInvite sends friend request to the provided peer identifier. The
 // identifier is a concatenation of node ID and hostname delimited with an
 // '@' sign.
-func (m *Manager) SendInvite(identifier string) error {
+func (m *Manager) SendInvite(ctx context.Context, identifier string) error {
 	nodeID, hostname, ok := p2p.ExtractIdentifier(identifier)
 	if !ok {
 		return ErrInvalidIdentifier
@@ -64,14 +130,84 @@ func (m *Manager) SendInvite(identifier string) error {
 		return ErrAlreadyFriend
 	}
 
-	// Send friend request to peer.
-	res, err := p2p.NewPeer(m.p2pClient, identifier).
-		FriendInvite(&p2p.FriendInviteRequest{
-			Hostname: m.commonConfig.Hostname,
-			Alias:    m.commonConfig.Alias,
-		})
+	res, err := m.sendInvite(ctx, nodeID, identifier)
 	if err != nil {
-		return fmt.Errorf("friend invite %s: %w", nodeID, err)
+		return err
+	}
+
+	// Keep the friend request as a pending request while it is queued.
+	if res == nil {
+		return m.saveOutgoingRequest(nodeID, hostname)
+	}
+
+	return m.handleInviteResponse(nodeID, hostname, res)
+}
+
+// sendInvite sends a friend request to the peer. If the peer is unreachable or
+// asks to retry later, the friend request is queued to be retried later and
+// the returned response is nil.
+func (m *Manager) sendInvite(ctx context.Context, nodeID string,
+	identifier string) (*p2p.FriendInviteResponse, error) {
+
+	commonConfig := m.CommonConfig()
+	req := &p2p.FriendInviteRequest{
+		Hostname: commonConfig.Hostname,
+		Alias:    commonConfig.Alias,
+	}
+	res, err := p2p.NewPeer(m.p2pClient, identifier).FriendInvite(ctx, req)
+	if err == nil {
+		return res, nil
+	}
+	if !p2p.IsTemporary(err) {
+		return nil, fmt.Errorf("friend invite %s: %w", nodeID, err)
+	}
+
+	log.Info().Err(err).Msgf("queueing friend invite to %s", nodeID)
+
+	// Replace the previously queued friend request, if any.
+	if err := m.outbox.Cancel(nodeID, p2p.EventFriendInvite); err != nil {
+		return nil, fmt.Errorf("cancel friend invite %s: %w", nodeID,
+			err)
+	}
+	if err := m.outbox.Enqueue(identifier, p2p.EventFriendInvite,
+		req); err != nil {
+
+		return nil, fmt.Errorf("enqueue friend invite %s: %w", nodeID,
+			err)
+	}
+	return nil, nil
+}
+
+// InviteDelivered handles the response of a friend request that has been
+// delivered from the outbox.
+func (m *Manager) InviteDelivered(msg *db.OutboxMessage,
+	resBytes []byte) error {
+
+	_, hostname, ok := p2p.ExtractIdentifier(msg.Identifier)
+	if !ok {
+		return ErrInvalidIdentifier
+	}
+
+	var res p2p.FriendInviteResponse
+	if err := proto.Unmarshal(resBytes, &res); err != nil {
+		return fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	return m.handleInviteResponse(msg.NodeID, hostname, &res)
+}
+
+// handleInviteResponse processes the peer's response to the friend request.
+// If the peer accepts the friend request, the peer becomes a friend.
+// Otherwise, the friend request is kept as a pending request.
+func (m *Manager) handleInviteResponse(nodeID string, hostname string,
+	res *p2p.FriendInviteResponse) error {
+
+	alreadyFriend, err := m.db.FriendExists(nodeID)
+	if err != nil {
+		return fmt.Errorf("find friend %s: %w", nodeID, err)
+	}
+	if alreadyFriend {
+		return nil
 	}
 
 	// If peer accepts friend request, insert into friend database.
@@ -82,6 +218,12 @@ func (m *Manager) SendInvite(identifier string) error {
 		}, res.Alias)
 	}
 
+	return m.saveOutgoingRequest(nodeID, hostname)
+}
+
+// saveOutgoingRequest creates or updates a friend request initiated by the
+// user.
+func (m *Manager) saveOutgoingRequest(nodeID string, hostname string) error {
 	// Find previously created friend request in the database.
 	friendReq, err := m.db.FindFriendRequest(nodeID)
 	if err != nil {
@@ -94,6 +236,7 @@ func (m *Manager) SendInvite(identifier string) error {
 		if _, err := m.db.CreateFriendRequest(
 			nodeID,
 			hostname,
+			"",
 			true,
 		); err != nil {
 			return fmt.Errorf("create friend req %s: %w", nodeID,
@@ -125,6 +268,15 @@ func (m *Manager) SendInvite(identifier string) error {
 func (m *Manager) ReceiveInvite(nodeID string,
 	req *p2p.FriendInviteRequest) (*p2p.FriendInviteResponse, error) {
 
+	// Reject the friend request if it advertises a blocked host.
+	allowed, err := m.AllowPeer(nodeID, hostOf(req.Hostname))
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, p2p.ErrBlocked
+	}
+
 	// Immediately return if the requester is already a friend.
 	alreadyFriend, err := m.db.FriendExists(nodeID)
 	if err != nil {
@@ -133,7 +285,7 @@ func (m *Manager) ReceiveInvite(nodeID string,
 	if alreadyFriend {
 		return &p2p.FriendInviteResponse{
 			Accepted: true,
-			Alias:    m.commonConfig.Alias,
+			Alias:    m.CommonConfig().Alias,
 		}, nil
 	}
 
@@ -148,12 +300,19 @@ func (m *Manager) ReceiveInvite(nodeID string,
 		if _, err := m.db.CreateFriendRequest(
 			nodeID,
 			req.Hostname,
+			req.Alias,
 			false,
 		); err != nil {
 			return nil, fmt.Errorf("create friend req %s: %w",
 				nodeID, err)
 		}
 
+		m.events.Publish(event.TypeFriendRequestReceived,
+			event.Friend{
+				NodeID:   nodeID,
+				Hostname: req.Hostname,
+				Alias:    req.Alias,
+			})
 		return &p2p.FriendInviteResponse{Accepted: false}, nil
 	}
 
@@ -166,12 +325,13 @@ func (m *Manager) ReceiveInvite(nodeID string,
 
 		return &p2p.FriendInviteResponse{
 			Accepted: true,
-			Alias:    m.commonConfig.Alias,
+			Alias:    m.CommonConfig().Alias,
 		}, nil
 	}
 
 	// Update friend request to the latest value.
 	friendReq.Hostname = req.Hostname
+	friendReq.Alias = req.Alias
 	if err := m.db.UpdateFriendRequest(friendReq); err != nil {
 		return nil, fmt.Errorf("update friend req %s: %w", nodeID, err)
 	}
@@ -179,6 +339,204 @@ func (m *Manager) ReceiveInvite(nodeID string,
 	return &p2p.FriendInviteResponse{Accepted: false}, nil
 }
 
+// ListFriends returns all friends of the user.
+func (m *Manager) ListFriends() ([]db.Friend, error) {
+	friends, err := m.db.ListFriends()
+	if err != nil {
+		return nil, fmt.Errorf("list friends: %w", err)
+	}
+	return friends, nil
+}
+
+// GetFriend returns a friend with the specified node ID.
+func (m *Manager) GetFriend(nodeID string) (*db.Friend, error) {
+	friend, err := m.db.FindFriend(nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("find friend %s: %w", nodeID, err)
+	}
+	if friend == nil {
+		return nil, ErrFriendNotFound
+	}
+	return friend, nil
+}
+
+// SetNickname sets a local display name of the friend with the specified node
+// ID. An empty nickname reverts to the alias provided by the friend.
+func (m *Manager) SetNickname(nodeID string, nickname string) (*db.Friend,
+	error) {
+
+	friend, err := m.GetFriend(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	friend.Nickname = nickname
+	if err := m.db.UpdateFriend(friend); err != nil {
+		return nil, fmt.Errorf("update friend %s: %w", nodeID, err)
+	}
+	return friend, nil
+}
+
+// RemoveFriend removes the friend with the specified node ID and notifies the
+// peer to remove the user from its friends as well. If the peer is
+// unreachable, the notification is queued to be retried later. Peers that do
+// not support FRIEND_REMOVE are not notified.
+func (m *Manager) RemoveFriend(ctx context.Context, nodeID string) error {
+	friend, err := m.GetFriend(nodeID)
+	if err != nil {
+		return err
+	}
+
+	if err := m.db.DeleteFriend(nodeID); err != nil {
+		return fmt.Errorf("delete friend %s: %w", nodeID, err)
+	}
+
+	identifier := p2p.CreateIdentifier(nodeID, friend.Hostname)
+	req := &p2p.FriendRemoveRequest{}
+	peer := p2p.NewPeer(m.p2pClient, identifier)
+	capabilities, err := peer.Capabilities(ctx)
+	if err == nil && !capabilities.Supports(p2p.EventFriendRemove) {
+		log.Info().Msgf("friend %s is not notified of the removal "+
+			"(version %d)", nodeID, capabilities.Version)
+		return nil
+	}
+	if err == nil {
+		_, err = peer.FriendRemove(ctx, req)
+	}
+	if err != nil && !p2p.IsTemporary(err) {
+		// The peer has responded, e.g. it has already removed the user.
+		log.Info().Err(err).Msgf("friend remove to %s", nodeID)
+	} else if err != nil {
+		log.Info().Err(err).Msgf("queueing friend remove to %s", nodeID)
+		if err := m.outbox.Enqueue(identifier, p2p.EventFriendRemove,
+			req); err != nil {
+
+			return fmt.Errorf("enqueue friend remove %s: %w",
+				nodeID, err)
+		}
+	}
+
+	return nil
+}
+
+// maySupport reports whether the friend may handle the event. It is false only
+// if the cached capabilities of the friend lack the event, so that messages to
+// friends that have not been contacted recently are still queued.
+func (m *Manager) maySupport(nodeID string, event string) bool {
+	capabilities, ok := m.p2pClient.CachedCapabilities(nodeID)
+	if ok && !capabilities.Supports(event) {
+		log.Debug().Msgf("friend %s does not support %s (version %d)",
+			nodeID, event, capabilities.Version)
+		return false
+	}
+	return true
+}
+
+// ReceiveRemove processes a notification from a peer that has removed the user
+// from its friends. The peer is removed from the user's friends as well.
+func (m *Manager) ReceiveRemove(nodeID string, _ *p2p.FriendRemoveRequest) (
+	*p2p.FriendRemoveResponse, error) {
+
+	if err := m.db.DeleteFriend(nodeID); err != nil {
+		return nil, fmt.Errorf("delete friend %s: %w", nodeID, err)
+	}
+	return &p2p.FriendRemoveResponse{}, nil
+}
+
+// ListIncomingRequests returns pending friend requests sent by peers to the
+// user.
+func (m *Manager) ListIncomingRequests() ([]db.FriendRequest, error) {
+	friendReqs, err := m.db.ListFriendRequests(false)
+	if err != nil {
+		return nil, fmt.Errorf("list incoming friend reqs: %w", err)
+	}
+	return friendReqs, nil
+}
+
+// ListOutgoingRequests returns pending friend requests sent by the user to
+// peers.
+func (m *Manager) ListOutgoingRequests() ([]db.FriendRequest, error) {
+	friendReqs, err := m.db.ListFriendRequests(true)
+	if err != nil {
+		return nil, fmt.Errorf("list outgoing friend reqs: %w", err)
+	}
+	return friendReqs, nil
+}
+
+// AcceptRequest accepts an incoming friend request from the peer with the
+// specified node ID. The peer is notified by sending a friend request back,
+// which the peer accepts since it has initiated the request. If the peer is
+// unreachable, the notification is queued and the peer becomes a friend
+// immediately.
+func (m *Manager) AcceptRequest(ctx context.Context, nodeID string) error {
+	friendReq, err := m.findRequest(nodeID, false)
+	if err != nil {
+		return err
+	}
+
+	identifier := p2p.CreateIdentifier(nodeID, friendReq.Hostname)
+	res, err := m.sendInvite(ctx, nodeID, identifier)
+	if err != nil {
+		return err
+	}
+
+	if res == nil {
+		return m.requestToFriend(friendReq, friendReq.Alias)
+	}
+
+	// The peer has withdrawn its friend request. Keep the user's friend
+	// request as an outgoing request instead.
+	if !res.Accepted {
+		return m.saveOutgoingRequest(nodeID, friendReq.Hostname)
+	}
+
+	return m.requestToFriend(friendReq, res.Alias)
+}
+
+// DeclineRequest silently deletes an incoming friend request from the peer
+// with the specified node ID.
+func (m *Manager) DeclineRequest(nodeID string) error {
+	if _, err := m.findRequest(nodeID, false); err != nil {
+		return err
+	}
+
+	if err := m.db.DeleteFriendRequest(nodeID); err != nil {
+		return fmt.Errorf("delete friend req %s: %w", nodeID, err)
+	}
+	return nil
+}
+
+// CancelRequest deletes an outgoing friend request to the peer with the
+// specified node ID and discards the queued friend request, if any.
+func (m *Manager) CancelRequest(nodeID string) error {
+	if _, err := m.findRequest(nodeID, true); err != nil {
+		return err
+	}
+
+	if err := m.outbox.Cancel(nodeID, p2p.EventFriendInvite); err != nil {
+		return fmt.Errorf("cancel friend invite %s: %w", nodeID, err)
+	}
+	if err := m.db.DeleteFriendRequest(nodeID); err != nil {
+		return fmt.Errorf("delete friend req %s: %w", nodeID, err)
+	}
+	return nil
+}
+
+// findRequest returns a pending friend request with the peer in the specified
+// direction. ErrRequestNotFound is returned if there is no such request.
+func (m *Manager) findRequest(nodeID string, isInitiator bool) (
+	*db.FriendRequest, error) {
+
+	friendReq, err := m.db.FindFriendRequest(nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("find friend req %s: %w", nodeID, err)
+	}
+	if friendReq == nil || friendReq.IsInitiator != isInitiator {
+		return nil, ErrRequestNotFound
+	}
+	return friendReq, nil
+}
+
 // requestToFriend converts friend request into friend. It fetches peer info API
 // for getting necessary information and stores to the friend database.
 // If there is a friend request previously created, this will delete it.
@@ -194,5 +552,42 @@ func (m *Manager) requestToFriend(friendReq *db.FriendRequest,
 			err)
 	}
 
+	m.events.Publish(event.TypeFriendAccepted, event.Friend{
+		NodeID:   friendReq.NodeID,
+		Hostname: friendReq.Hostname,
+		Alias:    alias,
+	})
 	return nil
 }
+
+// PeerStatus records the reachability of the peer with the specified node ID
+// and publishes an event when a friend goes online or offline.
+func (m *Manager) PeerStatus(nodeID string, online bool) {
+	m.presenceMu.Lock()
+	wasOnline, known := m.online[nodeID]
+	m.online[nodeID] = online
+	m.presenceMu.Unlock()
+
+	if known && wasOnline == online {
+		return
+	}
+
+	isFriend, err := m.db.FriendExists(nodeID)
+	if err != nil {
+		log.Error().Err(err).Msgf("error finding friend %s", nodeID)
+		return
+	}
+	if !isFriend {
+		// Only the presence of friends is tracked.
+		m.presenceMu.Lock()
+		delete(m.online, nodeID)
+		m.presenceMu.Unlock()
+		return
+	}
+
+	eventType := event.TypePeerOffline
+	if online {
+		eventType = event.TypePeerOnline
+	}
+	m.events.Publish(eventType, event.Peer{NodeID: nodeID})
+}
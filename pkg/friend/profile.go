@@ -0,0 +1,337 @@
+package friend
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/p2p"
+)
+
+// Setting keys of the profile fields set at runtime.
+const (
+	aliasSettingKey    = "profile.alias"
+	hostnameSettingKey = "profile.hostname"
+	bioSettingKey      = "profile.bio"
+	avatarSettingKey   = "profile.avatar"
+)
+
+const (
+	// maxBioLength is the maximum number of characters of a bio.
+	maxBioLength = 500
+
+	// maxAvatarLength is the maximum length of an avatar URL.
+	maxAvatarLength = 2048
+)
+
+// profileSettingKeys are the setting keys of all profile fields.
+var profileSettingKeys = []string{
+	aliasSettingKey,
+	hostnameSettingKey,
+	bioSettingKey,
+	avatarSettingKey,
+}
+
+// Profile is the information about the user that is shared with friends.
+type Profile struct {
+	// Alias is the display name that everyone can see.
+	Alias string
+
+	// Hostname is the address (host:port) at which peers reach the node.
+	Hostname string
+
+	// Bio is a short description of the user.
+	Bio string
+
+	// Avatar is the URL of the profile picture of the user.
+	Avatar string
+}
+
+// ProfileUpdate contains the profile fields to be changed. Nil fields are left
+// unchanged. An empty alias or hostname resets the field to the value in the
+// config.
+type ProfileUpdate struct {
+	Alias    *string
+	Hostname *string
+	Bio      *string
+	Avatar   *string
+}
+
+// LoadProfile reads the profile fields set at runtime from the database. It
+// must be called before the manager is used.
+func (m *Manager) LoadProfile() error {
+	settings := map[string]string{}
+	for _, key := range profileSettingKeys {
+		value, ok, err := m.db.GetSetting(key)
+		if err != nil {
+			return fmt.Errorf("get setting %s: %w", key, err)
+		}
+		if ok {
+			settings[key] = value
+		}
+	}
+
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	m.profileSettings = settings
+	return nil
+}
+
+// Profile returns the profile of the user. The alias and the hostname are
+// taken from the config unless they have been set at runtime.
+func (m *Manager) Profile() Profile {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+
+	return m.profile()
+}
+
+// profile returns the profile of the user. It must be called with configMu
+// held.
+func (m *Manager) profile() Profile {
+	profile := Profile{
+		Alias:    m.commonConfig.Alias,
+		Hostname: m.commonConfig.Hostname,
+		Bio:      m.profileSettings[bioSettingKey],
+		Avatar:   m.profileSettings[avatarSettingKey],
+	}
+	if alias, ok := m.profileSettings[aliasSettingKey]; ok {
+		profile.Alias = alias
+	}
+	if hostname, ok := m.profileSettings[hostnameSettingKey]; ok {
+		profile.Hostname = hostname
+	}
+	return profile
+}
+
+// OverriddenConfig returns the names of the fields of the common config that
+// have no effect because the profile field has been set at runtime.
+func (m *Manager) OverriddenConfig() []string {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+
+	fields := []string{}
+	if _, ok := m.profileSettings[aliasSettingKey]; ok {
+		fields = append(fields, "Alias")
+	}
+	if _, ok := m.profileSettings[hostnameSettingKey]; ok {
+		fields = append(fields, "Hostname")
+	}
+	return fields
+}
+
+// UpdateProfile changes the specified profile fields and stores them in the
+// database, where they take precedence over the config until they are reset.
+// If the profile changes, it is queued to be sent to all friends.
+func (m *Manager) UpdateProfile(update ProfileUpdate) (Profile, error) {
+	if err := validateProfileUpdate(update); err != nil {
+		return Profile{}, err
+	}
+
+	values := []*string{
+		update.Alias, update.Hostname, update.Bio, update.Avatar,
+	}
+
+	settings := map[string]string{}
+	for i, key := range profileSettingKeys {
+		if values[i] != nil {
+			settings[key] = *values[i]
+		}
+	}
+
+	m.configMu.Lock()
+	before := m.profile()
+	if err := m.db.SetSettings(settings); err != nil {
+		m.configMu.Unlock()
+		return Profile{}, fmt.Errorf("set profile settings: %w", err)
+	}
+	for key, value := range settings {
+		if value == "" {
+			delete(m.profileSettings, key)
+		} else {
+			m.profileSettings[key] = value
+		}
+	}
+	after := m.profile()
+	m.configMu.Unlock()
+
+	m.publishProfile(before, after)
+	return after, nil
+}
+
+// publishProfile notifies friends of the changes between the profiles. A new
+// hostname is also announced with a signature.
+func (m *Manager) publishProfile(before Profile, after Profile) {
+	if after != before {
+		m.broadcastProfile(after)
+	}
+	if after.Hostname != before.Hostname {
+		if err := m.AnnounceHostname(); err != nil {
+			log.Error().Err(err).Msg("error announcing hostname")
+		}
+	}
+}
+
+// broadcastProfile queues the profile to be sent to all friends, replacing the
+// previously queued profile, if any. Friends known not to support
+// PROFILE_UPDATE are skipped, and the outbox checks the others before
+// delivery.
+func (m *Manager) broadcastProfile(profile Profile) {
+	friends, err := m.db.ListFriends()
+	if err != nil {
+		log.Error().Err(err).Msg("error listing friends for profile " +
+			"update")
+		return
+	}
+
+	req := &p2p.ProfileUpdateRequest{
+		Alias:     profile.Alias,
+		Hostname:  profile.Hostname,
+		Bio:       profile.Bio,
+		Avatar:    profile.Avatar,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	for i := range friends {
+		nodeID := friends[i].NodeID
+		if !m.maySupport(nodeID, p2p.EventProfileUpdate) {
+			continue
+		}
+		if err := m.outbox.Cancel(nodeID,
+			p2p.EventProfileUpdate); err != nil {
+
+			log.Error().Err(err).Msgf("error canceling profile "+
+				"update to %s", nodeID)
+			continue
+		}
+		identifier := p2p.CreateIdentifier(nodeID, friends[i].Hostname)
+		if err := m.outbox.Enqueue(identifier, p2p.EventProfileUpdate,
+			req); err != nil {
+
+			log.Error().Err(err).Msgf("error queueing profile "+
+				"update to %s", nodeID)
+		}
+	}
+}
+
+// ReceiveProfileUpdate processes the profile sent by a friend after it
+// changes. The stored profile of the friend is replaced, except the hostname
+// if the friend has announced another hostname later. The undelivered messages
+// to the friend are sent to the new hostname.
+func (m *Manager) ReceiveProfileUpdate(nodeID string,
+	req *p2p.ProfileUpdateRequest) (*p2p.ProfileUpdateResponse, error) {
+
+	if err := validateProfile(Profile{
+		Alias:    req.Alias,
+		Hostname: req.Hostname,
+		Bio:      req.Bio,
+		Avatar:   req.Avatar,
+	}); err != nil {
+		return nil, p2p.BadRequest(err)
+	}
+	sentAt := time.UnixMilli(req.Timestamp)
+	if err := m.checkFriendHostname(nodeID, req.Hostname,
+		sentAt); err != nil {
+
+		return nil, err
+	}
+
+	friend, err := m.db.FindFriend(nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("find friend %s: %w", nodeID, err)
+	}
+	if friend == nil {
+		return nil, p2p.ErrNotFriend
+	}
+
+	friend.Alias = req.Alias
+	friend.Bio = req.Bio
+	friend.Avatar = req.Avatar
+	if err := m.updateFriendHostname(friend, req.Hostname,
+		sentAt); err != nil {
+
+		return nil, err
+	}
+	return &p2p.ProfileUpdateResponse{}, nil
+}
+
+// validateProfileUpdate checks the fields to be changed. Empty aliases and
+// hostnames are valid as they reset the fields.
+func validateProfileUpdate(update ProfileUpdate) error {
+	if update.Alias != nil && *update.Alias != "" {
+		if err := validateAlias(*update.Alias); err != nil {
+			return err
+		}
+	}
+	if update.Hostname != nil && *update.Hostname != "" {
+		if err := validateHostname(*update.Hostname); err != nil {
+			return err
+		}
+	}
+	if update.Bio != nil {
+		if err := validateBio(*update.Bio); err != nil {
+			return err
+		}
+	}
+	if update.Avatar != nil {
+		return validateAvatar(*update.Avatar)
+	}
+	return nil
+}
+
+// validateProfile checks the fields of a profile sent by a friend.
+func validateProfile(profile Profile) error {
+	if err := validateAlias(profile.Alias); err != nil {
+		return err
+	}
+	if err := validateHostname(profile.Hostname); err != nil {
+		return err
+	}
+	if err := validateBio(profile.Bio); err != nil {
+		return err
+	}
+	return validateAvatar(profile.Avatar)
+}
+
+func validateAlias(alias string) error {
+	if err := p2p.ValidateAlias(alias); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidProfile, err.Error())
+	}
+	return nil
+}
+
+func validateHostname(hostname string) error {
+	if err := p2p.ValidateHostname(hostname); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidProfile, err.Error())
+	}
+	return nil
+}
+
+func validateBio(bio string) error {
+	if utf8.RuneCountInString(bio) > maxBioLength {
+		return fmt.Errorf("%w: bio must be at most %d characters",
+			ErrInvalidProfile, maxBioLength)
+	}
+	return nil
+}
+
+// validateAvatar checks that the avatar is empty or an HTTP or HTTPS URL.
+func validateAvatar(avatar string) error {
+	if avatar == "" {
+		return nil
+	}
+	if len(avatar) > maxAvatarLength {
+		return fmt.Errorf("%w: avatar must be at most %d bytes",
+			ErrInvalidProfile, maxAvatarLength)
+	}
+	u, err := url.Parse(avatar)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+		u.Host == "" {
+
+		return fmt.Errorf("%w: avatar must be an http or https URL",
+			ErrInvalidProfile)
+	}
+	return nil
+}
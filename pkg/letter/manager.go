@@ -0,0 +1,307 @@
+package letter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/event"
+	"github.com/sunboyy/lettered/pkg/outbox"
+	"github.com/sunboyy/lettered/pkg/p2p"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// ErrNotFriend is returned when sending a letter to a peer that is not
+	// a friend of the user.
+	ErrNotFriend = errors.New("not a friend")
+
+	// ErrEmptyLetter is returned when sending a letter without a body.
+	ErrEmptyLetter = errors.New("letter body is empty")
+
+	// ErrLetterRejected is returned when the peer does not accept the
+	// letter sent by the user.
+	ErrLetterRejected = errors.New("letter rejected by peer")
+
+	// ErrLetterNotFound is returned when there is no letter with the
+	// specified ID.
+	ErrLetterNotFound = errors.New("letter not found")
+
+	// errEmptyLetterID is an error indicating that the received letter has
+	// no letter ID.
+	errEmptyLetterID = errors.New("empty letter id")
+)
+
+// Manager contains a set of functionalities managing user's letters.
+type Manager struct {
+	db        *db.DB
+	p2pClient *p2p.Client
+	outbox    *outbox.Worker
+	events    *event.Bus
+}
+
+// NewManager is a constructor of Manager.
+func NewManager(db *db.DB, p2pClient *p2p.Client, outbox *outbox.Worker,
+	events *event.Bus) *Manager {
+
+	return &Manager{
+		db:        db,
+		p2pClient: p2pClient,
+		outbox:    outbox,
+		events:    events,
+	}
+}
+
+// SendLetter composes a new letter and delivers it to the friend with the
+// specified node ID. The letter is stored in the outbox once the friend
+// accepts it. If the friend is unreachable, the letter is stored as pending
+// and queued to be delivered later.
+func (m *Manager) SendLetter(ctx context.Context, nodeID string,
+	subject string, body string) (*db.Letter, error) {
+
+	if body == "" {
+		return nil, ErrEmptyLetter
+	}
+
+	friend, err := m.db.FindFriend(nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("find friend %s: %w", nodeID, err)
+	}
+	if friend == nil {
+		return nil, ErrNotFriend
+	}
+
+	letterID, err := generateLetterID()
+	if err != nil {
+		return nil, err
+	}
+
+	letter := &db.Letter{
+		LetterID: letterID,
+		NodeID:   nodeID,
+		Box:      db.LetterBoxOutbox,
+		Subject:  subject,
+		Body:     body,
+		SentAt:   time.Now(),
+	}
+
+	identifier := p2p.CreateIdentifier(friend.NodeID, friend.Hostname)
+	req := &p2p.LetterSendRequest{
+		Letter: &p2p.Letter{
+			Id:      letter.LetterID,
+			Subject: letter.Subject,
+			Body:    letter.Body,
+			SentAt:  letter.SentAt.Unix(),
+		},
+	}
+	res, err := p2p.NewPeer(m.p2pClient, identifier).LetterSend(ctx, req)
+	if err != nil && !p2p.IsTemporary(err) {
+		return nil, fmt.Errorf("letter send %s: %w", nodeID, err)
+	}
+	if err != nil {
+		log.Info().Err(err).Msgf("queueing letter to %s", nodeID)
+
+		letter.Status = db.LetterStatusPending
+		if err := m.db.CreateLetter(letter); err != nil {
+			return nil, fmt.Errorf("create letter %s: %w", letterID,
+				err)
+		}
+		if err := m.outbox.Enqueue(identifier, p2p.EventLetterSend,
+			req); err != nil {
+
+			return nil, fmt.Errorf("enqueue letter %s: %w",
+				letterID, err)
+		}
+		return letter, nil
+	}
+	if !res.Accepted {
+		return nil, ErrLetterRejected
+	}
+
+	letter.Status = db.LetterStatusDelivered
+	if err := m.db.CreateLetter(letter); err != nil {
+		return nil, fmt.Errorf("create letter %s: %w", letterID, err)
+	}
+
+	return letter, nil
+}
+
+// LetterDelivered updates the status of a letter that has been delivered from
+// the outbox queue according to the response of the recipient.
+func (m *Manager) LetterDelivered(msg *db.OutboxMessage,
+	resBytes []byte) error {
+
+	var res p2p.LetterSendResponse
+	if err := proto.Unmarshal(resBytes, &res); err != nil {
+		return fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	status := db.LetterStatusDelivered
+	if !res.Accepted {
+		status = db.LetterStatusFailed
+	}
+	return m.updateQueuedLetterStatus(msg, status)
+}
+
+// LetterFailed marks a letter that cannot be delivered from the outbox queue
+// as failed.
+func (m *Manager) LetterFailed(msg *db.OutboxMessage) error {
+	return m.updateQueuedLetterStatus(msg, db.LetterStatusFailed)
+}
+
+func (m *Manager) updateQueuedLetterStatus(msg *db.OutboxMessage,
+	status db.LetterStatus) error {
+
+	var req p2p.LetterSendRequest
+	if err := proto.Unmarshal(msg.Body, &req); err != nil {
+		return fmt.Errorf("unmarshal request: %w", err)
+	}
+
+	letterID := req.GetLetter().GetId()
+	letter, err := m.db.FindLetterByLetterID(db.LetterBoxOutbox, msg.NodeID,
+		letterID)
+	if err != nil {
+		return fmt.Errorf("find letter %s: %w", letterID, err)
+	}
+	if letter == nil {
+		// The user has deleted the letter while it is being queued.
+		return nil
+	}
+
+	letter.Status = status
+	if err := m.db.UpdateLetter(letter); err != nil {
+		return fmt.Errorf("update letter %s: %w", letterID, err)
+	}
+
+	if status == db.LetterStatusFailed {
+		m.events.Publish(event.TypeDeliveryFailed, letterEvent(letter))
+	}
+	return nil
+}
+
+// ReceiveLetter processes a letter sent by a peer. Letters are only accepted
+// from friends of the user, otherwise p2p.ErrNotFriend is returned. If the
+// same letter is delivered multiple times, it is stored only once.
+func (m *Manager) ReceiveLetter(nodeID string, req *p2p.LetterSendRequest) (
+	*p2p.LetterSendResponse, error) {
+
+	isFriend, err := m.db.FriendExists(nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("find friend %s: %w", nodeID, err)
+	}
+	if !isFriend {
+		return nil, p2p.ErrNotFriend
+	}
+
+	letterID := req.GetLetter().GetId()
+	if letterID == "" {
+		return nil, p2p.BadRequest(errEmptyLetterID)
+	}
+
+	// Acknowledge the letter without storing if it has been received
+	// before, even if the user has deleted it since.
+	exists, err := m.db.LetterExists(db.LetterBoxInbox, nodeID, letterID)
+	if err != nil {
+		return nil, fmt.Errorf("find letter %s: %w", letterID, err)
+	}
+	if exists {
+		return &p2p.LetterSendResponse{Accepted: true}, nil
+	}
+
+	letter := &db.Letter{
+		LetterID: letterID,
+		NodeID:   nodeID,
+		Box:      db.LetterBoxInbox,
+		Status:   db.LetterStatusDelivered,
+		Subject:  req.GetLetter().GetSubject(),
+		Body:     req.GetLetter().GetBody(),
+		SentAt:   time.Unix(req.GetLetter().GetSentAt(), 0),
+	}
+	// The same letter may be received concurrently on another connection
+	// when the sender retries.
+	created, err := m.db.CreateLetterIfNotExists(letter)
+	if err != nil {
+		return nil, fmt.Errorf("create letter %s: %w", letterID, err)
+	}
+	if !created {
+		return &p2p.LetterSendResponse{Accepted: true}, nil
+	}
+
+	m.events.Publish(event.TypeLetterReceived, letterEvent(letter))
+	return &p2p.LetterSendResponse{Accepted: true}, nil
+}
+
+// ListLetters returns letters in the specified box, newest first, together
+// with the total number of letters in the box.
+func (m *Manager) ListLetters(box db.LetterBox, offset int, limit int) (
+	[]db.Letter, int64, error) {
+
+	letters, total, err := m.db.ListLetters(box, offset, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list letters %s: %w", box, err)
+	}
+	return letters, total, nil
+}
+
+// GetLetter returns a letter with the specified ID.
+func (m *Manager) GetLetter(id uint) (*db.Letter, error) {
+	letter, err := m.db.FindLetter(id)
+	if err != nil {
+		return nil, fmt.Errorf("find letter %d: %w", id, err)
+	}
+	if letter == nil {
+		return nil, ErrLetterNotFound
+	}
+	return letter, nil
+}
+
+// MarkRead marks a letter with the specified ID as read or unread.
+func (m *Manager) MarkRead(id uint, isRead bool) (*db.Letter, error) {
+	letter, err := m.GetLetter(id)
+	if err != nil {
+		return nil, err
+	}
+
+	letter.IsRead = isRead
+	if err := m.db.UpdateLetter(letter); err != nil {
+		return nil, fmt.Errorf("update letter %d: %w", id, err)
+	}
+	return letter, nil
+}
+
+// DeleteLetter deletes a letter with the specified ID.
+func (m *Manager) DeleteLetter(id uint) error {
+	if _, err := m.GetLetter(id); err != nil {
+		return err
+	}
+
+	if err := m.db.DeleteLetter(id); err != nil {
+		return fmt.Errorf("delete letter %d: %w", id, err)
+	}
+	return nil
+}
+
+// letterEvent returns the event data of the letter. The body is left out so
+// that events stay small.
+func letterEvent(letter *db.Letter) event.Letter {
+	return event.Letter{
+		ID:       letter.ID,
+		LetterID: letter.LetterID,
+		NodeID:   letter.NodeID,
+		Subject:  letter.Subject,
+		SentAt:   letter.SentAt,
+	}
+}
+
+func generateLetterID() (string, error) {
+	letterIDBytes := make([]byte, 16)
+	if _, err := rand.Read(letterIDBytes); err != nil {
+		return "", fmt.Errorf("rand read: %w", err)
+	}
+	return hex.EncodeToString(letterIDBytes), nil
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ErrorResponse defines a response body of the management APIs when the
+// request fails. PeerErrorCode is only set when the error is returned by a
+// peer.
+type ErrorResponse struct {
+	Error         string `json:"error"`
+	PeerErrorCode string `json:"peerErrorCode,omitempty"`
+}
+
+// IdentityResponse defines response body of the identity management API.
+type IdentityResponse struct {
+	Identifier string `json:"identifier"`
+}
+
+// Event defines an event sent on the event stream API. The schema of Data
+// depends on Type.
+type Event struct {
+	ID   uint64          `json:"id"`
+	Type string          `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
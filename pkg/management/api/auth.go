@@ -0,0 +1,19 @@
+package api
+
+import "time"
+
+// ManagementLoginRequest defines a request body of the management login API.
+type ManagementLoginRequest struct {
+	Password string `json:"password"`
+}
+
+// ManagementSetupRequest defines a request body of the management setup API.
+type ManagementSetupRequest struct {
+	Password string `json:"password"`
+}
+
+// ManagementLoginResponse defines a response body of the management login API.
+type ManagementLoginResponse struct {
+	AccessToken string    `json:"accessToken"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
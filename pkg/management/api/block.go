@@ -0,0 +1,23 @@
+package api
+
+import "time"
+
+// ListBlocksResponse defines a response body of the block listing API.
+type ListBlocksResponse struct {
+	Blocks []BlockResponse `json:"blocks"`
+}
+
+// BlockResponse defines a block in the response body of the block management
+// APIs.
+type BlockResponse struct {
+	ID        uint      `json:"id"`
+	NodeID    string    `json:"nodeId"`
+	Host      string    `json:"host"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// BlockRequest defines a request body of the block API.
+type BlockRequest struct {
+	NodeID string `json:"nodeId"`
+	Host   string `json:"host"`
+}
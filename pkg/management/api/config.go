@@ -0,0 +1,16 @@
+package api
+
+// ReloadConfigResponse defines a response body of the config reloading API.
+type ReloadConfigResponse struct {
+	// Applied are the keys of the changed options that are applied to the
+	// running node.
+	Applied []string `json:"applied"`
+
+	// RestartRequired are the keys of the changed options that take effect
+	// after the node is restarted.
+	RestartRequired []string `json:"restartRequired"`
+
+	// Overridden are the keys of the changed options that have no effect
+	// because the profile field has been set with the profile API.
+	Overridden []string `json:"overridden"`
+}
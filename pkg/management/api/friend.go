@@ -0,0 +1,46 @@
+package api
+
+import "time"
+
+// SendInviteRequest defines a request body of the friend invite API.
+type SendInviteRequest struct {
+	Identifier string `json:"identifier"`
+}
+
+// ListFriendsResponse defines a response body of the friend listing API.
+type ListFriendsResponse struct {
+	Friends []FriendResponse `json:"friends"`
+}
+
+// FriendResponse defines a friend in the response body of the friend
+// management APIs.
+type FriendResponse struct {
+	NodeID     string    `json:"nodeId"`
+	Identifier string    `json:"identifier"`
+	Hostname   string    `json:"hostname"`
+	Alias      string    `json:"alias"`
+	Nickname   string    `json:"nickname"`
+	Bio        string    `json:"bio"`
+	Avatar     string    `json:"avatar"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// UpdateFriendRequest defines a request body of the friend update API.
+type UpdateFriendRequest struct {
+	Nickname string `json:"nickname"`
+}
+
+// ListFriendRequestsResponse defines a response body of the friend request
+// listing APIs.
+type ListFriendRequestsResponse struct {
+	Requests []FriendRequestResponse `json:"requests"`
+}
+
+// FriendRequestResponse defines a friend request in the response body of the
+// friend request management APIs.
+type FriendRequestResponse struct {
+	NodeID    string    `json:"nodeId"`
+	Hostname  string    `json:"hostname"`
+	Alias     string    `json:"alias"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
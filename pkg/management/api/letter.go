@@ -0,0 +1,32 @@
+package api
+
+import "time"
+
+// SendLetterRequest defines a request body of the send letter API.
+type SendLetterRequest struct {
+	NodeID  string `json:"nodeId"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// LetterResponse defines a letter in the response body of the letter
+// management APIs.
+type LetterResponse struct {
+	ID       uint      `json:"id"`
+	LetterID string    `json:"letterId"`
+	NodeID   string    `json:"nodeId"`
+	Box      string    `json:"box"`
+	Status   string    `json:"status"`
+	Subject  string    `json:"subject"`
+	Body     string    `json:"body"`
+	SentAt   time.Time `json:"sentAt"`
+	IsRead   bool      `json:"isRead"`
+}
+
+// ListLettersResponse defines a response body of the letter listing APIs.
+type ListLettersResponse struct {
+	Letters  []LetterResponse `json:"letters"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"pageSize"`
+	Total    int64            `json:"total"`
+}
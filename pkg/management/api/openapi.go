@@ -0,0 +1,9 @@
+package api
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI 3 document describing the management API. It must
+// be updated together with the handlers and the types in this package.
+//
+//go:embed openapi.json
+var OpenAPI []byte
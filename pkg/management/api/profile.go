@@ -0,0 +1,20 @@
+package api
+
+// ProfileResponse defines a response body of the profile APIs.
+type ProfileResponse struct {
+	Identifier string `json:"identifier"`
+	Alias      string `json:"alias"`
+	Hostname   string `json:"hostname"`
+	Bio        string `json:"bio"`
+	Avatar     string `json:"avatar"`
+}
+
+// UpdateProfileRequest defines a request body of the profile update API.
+// Omitted fields are left unchanged. An empty alias or hostname resets the
+// field to the value in the config.
+type UpdateProfileRequest struct {
+	Alias    *string `json:"alias"`
+	Hostname *string `json:"hostname"`
+	Bio      *string `json:"bio"`
+	Avatar   *string `json:"avatar"`
+}
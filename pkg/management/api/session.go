@@ -0,0 +1,25 @@
+package api
+
+import "time"
+
+// ListSessionsResponse defines a response body of the session listing API.
+type ListSessionsResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+}
+
+// SessionResponse defines a session in the response body of the session
+// management APIs. Current is true for the session of the request.
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	ClientIP   string    `json:"clientIp"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+// RevokeSessionsResponse defines a response body of the API revoking other
+// sessions.
+type RevokeSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
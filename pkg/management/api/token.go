@@ -0,0 +1,32 @@
+package api
+
+import "time"
+
+// CreateTokenRequest defines a request body of the API token creation API.
+type CreateTokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// CreateTokenResponse defines a response body of the API token creation API.
+type CreateTokenResponse struct {
+	Token string `json:"token"`
+	TokenResponse
+}
+
+// ListTokensResponse defines a response body of the API token listing API.
+type ListTokensResponse struct {
+	Tokens []TokenResponse `json:"tokens"`
+}
+
+// TokenResponse defines an API token in the response body of the API token
+// management APIs.
+type TokenResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
@@ -0,0 +1,53 @@
+package api
+
+import "time"
+
+// CreateWebhookRequest defines a request body of the webhook creation API.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// CreateWebhookResponse defines a response body of the webhook creation API.
+type CreateWebhookResponse struct {
+	Secret string `json:"secret"`
+	WebhookResponse
+}
+
+// ListWebhooksResponse defines a response body of the webhook listing API.
+type ListWebhooksResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+// WebhookResponse defines a webhook in the response body of the webhook
+// management APIs.
+type WebhookResponse struct {
+	ID        uint      `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ListWebhookDeliveriesResponse defines a response body of the webhook
+// delivery log API.
+type ListWebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	Page       int                       `json:"page"`
+	PageSize   int                       `json:"pageSize"`
+	Total      int64                     `json:"total"`
+}
+
+// WebhookDeliveryResponse defines a delivery in the response body of the
+// webhook delivery log API. NextAttemptAt is only set for pending
+// deliveries.
+type WebhookDeliveryResponse struct {
+	ID             uint       `json:"id"`
+	Event          string     `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"responseStatus"`
+	LastError      string     `json:"lastError"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt"`
+}
@@ -5,71 +5,249 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"time"
+	"sync"
 
-	"github.com/patrickmn/go-cache"
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/db"
+	"golang.org/x/crypto/bcrypt"
 )
 
-// ErrIncorrectPassword is returned on Login when the given password is
-// incorrect.
-var ErrIncorrectPassword = errors.New("incorrect password")
+const (
+	// DefaultPassword is the well-known password of the earlier default
+	// configuration. It is never accepted as the administrator password.
+	DefaultPassword = "letteradm"
+
+	// minPasswordLength is the minimum length of a new password.
+	minPasswordLength = 8
+
+	// passwordHashKey is the setting key of the administrator password
+	// hash.
+	passwordHashKey = "management.password_hash"
+)
+
+var (
+	// ErrIncorrectPassword is returned on Login when the given password is
+	// incorrect.
+	ErrIncorrectPassword = errors.New("incorrect password")
+
+	// ErrSetupRequired is returned on Login when the administrator
+	// password has not been set up.
+	ErrSetupRequired = errors.New("password setup required")
+
+	// ErrAlreadySetUp is returned on Setup when the administrator password
+	// has already been set up.
+	ErrAlreadySetUp = errors.New("password already set up")
+
+	// ErrPasswordTooShort is returned when setting a password that is
+	// shorter than the minimum length.
+	ErrPasswordTooShort = errors.New("password is too short")
+
+	// ErrDefaultPassword is returned when setting the default password.
+	ErrDefaultPassword = errors.New("default password is not allowed")
+
+	// ErrSessionNotFound is returned when there is no active session with
+	// the specified ID.
+	ErrSessionNotFound = errors.New("session not found")
+)
 
 // Auth contains utilities that is used for authenticating the administrator to
-// manage the system.
+// manage the system. The administrator password is stored in the database as
+// a bcrypt hash.
 type Auth struct {
-	// password is an administrator password.
-	password string
+	// config is the configuration of Auth. configMu guards the options
+	// that can be updated while running.
+	config   Config
+	configMu sync.RWMutex
+
+	db *db.DB
+
+	// setupMu prevents concurrent setups from overwriting each other.
+	setupMu sync.Mutex
 
-	// sessionCache is a cache that stores valid access tokens after
-	// authentication succeeds.
-	sessionCache *cache.Cache
+	// sessionMu guards sessions.
+	sessionMu sync.Mutex
+
+	// sessions stores active sessions by the hash of their access tokens.
+	sessions map[string]*db.Session
+
+	// throttle locks out logging in after too many failed attempts.
+	throttle *loginThrottle
 }
 
 // NewAuth is a constructor for the Auth struct. It uses config parameter for
-// setting up the properties of the Auth struct.
-func NewAuth(config Config) *Auth {
-	return &Auth{
-		password: config.Password,
-		sessionCache: cache.New(
-			time.Second*time.Duration(config.SessionTimeout),
-			time.Minute*10,
-		),
+// setting up the properties of the Auth struct. If no password hash has been
+// stored, the password in the config, if any, is hashed and stored. The
+// default password in the config is ignored. Persisted sessions are restored
+// if enabled.
+func NewAuth(config Config, database *db.DB) (*Auth, error) {
+	a := &Auth{
+		config:   config,
+		db:       database,
+		sessions: map[string]*db.Session{},
+		throttle: newLoginThrottle(config),
+	}
+
+	if err := a.seedPassword(config.Password); err != nil {
+		return nil, err
+	}
+	if err := a.loadSessions(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Auth) seedPassword(password string) error {
+	if password == "" {
+		return nil
+	}
+	if password == DefaultPassword {
+		log.Warn().Msg("management: ignoring the default password " +
+			"in the config")
+		return nil
+	}
+
+	_, ok, err := a.passwordHash()
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+
+	log.Info().Msg("management: storing the password in the config " +
+		"as a hash")
+	return a.setPassword(password)
+}
+
+// UpdateConfig applies the options of the config that can be changed while
+// running: the session timeout and the login lockout. The session timeout
+// applies to sessions created or refreshed afterwards. PersistSessions and
+// Password only take effect on start.
+func (a *Auth) UpdateConfig(config Config) {
+	a.configMu.Lock()
+	a.config.SessionTimeout = config.SessionTimeout
+	a.config.LoginAttempts = config.LoginAttempts
+	a.config.GlobalLoginAttempts = config.GlobalLoginAttempts
+	a.config.LoginLockout = config.LoginLockout
+	a.config.MaxLoginLockout = config.MaxLoginLockout
+	a.configMu.Unlock()
+
+	a.throttle.update(config)
+}
+
+// SetupRequired returns true if the administrator password has not been set
+// up. Logging in is not possible until then.
+func (a *Auth) SetupRequired() (bool, error) {
+	_, ok, err := a.passwordHash()
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+// Setup sets the first administrator password and logs in with it from the
+// client IP. It returns ErrAlreadySetUp if the password has been set up.
+func (a *Auth) Setup(password string, clientIP string) (string, *db.Session,
+	error) {
+
+	a.setupMu.Lock()
+	defer a.setupMu.Unlock()
+
+	required, err := a.SetupRequired()
+	if err != nil {
+		return "", nil, err
+	}
+	if !required {
+		return "", nil, ErrAlreadySetUp
+	}
+
+	if err := a.SetPassword(password); err != nil {
+		return "", nil, err
+	}
+	return a.createSession(clientIP)
+}
+
+// SetPassword validates and stores a new administrator password. Existing
+// sessions remain valid.
+func (a *Auth) SetPassword(password string) error {
+	if len(password) < minPasswordLength {
+		return ErrPasswordTooShort
 	}
+	if password == DefaultPassword {
+		return ErrDefaultPassword
+	}
+	return a.setPassword(password)
+}
+
+func (a *Auth) setPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password),
+		bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("generate password hash: %w", err)
+	}
+
+	if err := a.db.SetSetting(passwordHashKey, string(hash)); err != nil {
+		return fmt.Errorf("set password hash: %w", err)
+	}
+	return nil
 }
 
-// Login creates and returns a new access token if the provided password matches
-// the password in the configuration. The newly generated access token is stored
-// in the sessionCache so that it can be used for further requests.
-func (a *Auth) Login(password string) (string, error) {
-	if password != a.password {
-		return "", ErrIncorrectPassword
+// Login creates a new session and returns its access token if the provided
+// password matches the stored password hash. The session records the client
+// IP that it is used from. After too many failed attempts, a LockedOutError
+// is returned without checking the password.
+func (a *Auth) Login(password string, clientIP string) (string, *db.Session,
+	error) {
+
+	if retryAfter := a.throttle.check(clientIP); retryAfter > 0 {
+		log.Warn().Msgf("management: locked out login attempt from %s",
+			clientIP)
+		return "", nil, &LockedOutError{RetryAfter: retryAfter}
 	}
 
-	accessToken, err := generateAccessToken()
+	hash, ok, err := a.passwordHash()
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	if !ok {
+		return "", nil, ErrSetupRequired
 	}
-	if err := a.sessionCache.Add(accessToken, nil,
-		cache.DefaultExpiration); err != nil {
 
-		return "", fmt.Errorf("add session cache: %w", err)
+	// The comparison takes constant time regardless of the password.
+	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		failures := a.throttle.fail(clientIP)
+		log.Warn().Msgf("management: failed login attempt from %s "+
+			"(%d consecutive)", clientIP, failures)
+		return "", nil, ErrIncorrectPassword
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("compare password hash: %w", err)
 	}
 
-	return accessToken, nil
+	a.throttle.succeed(clientIP)
+	return a.createSession(clientIP)
 }
 
-// AccessTokenValid returns true if the provided access token exists in the
-// sessionCache, meaning that the access token can still be used for further
-// requests.
-func (a *Auth) AccessTokenValid(accessToken string) bool {
-	_, ok := a.sessionCache.Get(accessToken)
-	return ok
+// passwordHash returns the stored password hash. The hash is read on every
+// use so that a password changed by the passwd command takes effect without
+// restarting.
+func (a *Auth) passwordHash() (string, bool, error) {
+	hash, ok, err := a.db.GetSetting(passwordHashKey)
+	if err != nil {
+		return "", false, fmt.Errorf("get password hash: %w", err)
+	}
+	return hash, ok, nil
 }
 
 func generateAccessToken() (string, error) {
-	accessTokenBytes := make([]byte, 16)
-	if _, err := rand.Read(accessTokenBytes); err != nil {
+	return randomHex(16)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
 		return "", fmt.Errorf("rand read: %w", err)
 	}
-	return hex.EncodeToString(accessTokenBytes), nil
+	return hex.EncodeToString(b), nil
 }
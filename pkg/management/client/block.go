@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// ListBlocks returns the blocked node IDs and hosts.
+func (c *Client) ListBlocks(ctx context.Context) ([]api.BlockResponse, error) {
+	var res api.ListBlocksResponse
+	if err := c.do(ctx, http.MethodGet, "/blocks", nil, nil,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return res.Blocks, nil
+}
+
+// Block blocks a node ID, a host or both.
+func (c *Client) Block(ctx context.Context, req api.BlockRequest) (
+	*api.BlockResponse, error) {
+
+	var res api.BlockResponse
+	if err := c.do(ctx, http.MethodPost, "/blocks", nil, req,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Unblock removes the block with the specified ID.
+func (c *Client) Unblock(ctx context.Context, id uint) error {
+	return c.do(ctx, http.MethodDelete, idPath("/blocks", id), nil, nil,
+		nil)
+}
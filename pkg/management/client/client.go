@@ -0,0 +1,198 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// basePath is the path prefix of the management API.
+const basePath = "/management"
+
+// ResponseError is returned when the management API responds with an error
+// status code.
+type ResponseError struct {
+	StatusCode int
+	Message    string
+
+	// PeerErrorCode is the error code returned by the peer when the
+	// request fails at the peer. It is empty otherwise.
+	PeerErrorCode string
+
+	// RetryAfter is the duration to wait before retrying. It is only set
+	// when the API responds with a Retry-After header.
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *ResponseError) Error() string {
+	if e.PeerErrorCode != "" {
+		return fmt.Sprintf("management api: %d %s: %s", e.StatusCode,
+			e.PeerErrorCode, e.Message)
+	}
+	return fmt.Sprintf("management api: %d: %s", e.StatusCode, e.Message)
+}
+
+// Client is a client of the management API of a lettered node. Requests are
+// authenticated with the token set by NewClient, SetToken, Login or Setup,
+// which can be either the access token of a login session or an API token.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	// tokenMu guards token.
+	tokenMu sync.RWMutex
+	token   string
+}
+
+// NewClient is a constructor of Client. baseURL is the address of the
+// management server, e.g. http://localhost:11926. The token may be empty if
+// the client logs in later.
+func NewClient(baseURL string, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/") + basePath,
+		httpClient: &http.Client{},
+		token:      token,
+	}
+}
+
+// Token returns the token used for authenticating requests.
+func (c *Client) Token() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+
+	return c.token
+}
+
+// SetToken replaces the token used for authenticating requests.
+func (c *Client) SetToken(token string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	c.token = token
+}
+
+// OpenAPI returns the OpenAPI document of the management API served by the
+// node.
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var res json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/openapi.json", nil, nil,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return res, nil
+}
+
+// do sends a request with the JSON-encoded reqBody, if not nil, and decodes
+// the response body into resBody, if not nil. It returns a ResponseError if
+// the API responds with an error status code.
+func (c *Client) do(ctx context.Context, method string, path string,
+	query url.Values, reqBody interface{}, resBody interface{}) error {
+
+	res, err := c.send(ctx, method, path, query, reqBody)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if resBody == nil {
+		resBody = &struct{}{}
+	}
+	if err := json.NewDecoder(res.Body).Decode(resBody); err != nil {
+		return fmt.Errorf("decode response of %s %s: %w", method, path,
+			err)
+	}
+	return nil
+}
+
+// send sends a request and returns the response if the API responds with a
+// successful status code. The caller must close the response body.
+func (c *Client) send(ctx context.Context, method string, path string,
+	query url.Values, reqBody interface{}) (*http.Response, error) {
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if reqBody != nil {
+		b, err := json.Marshal(reqBody)
+		if err != nil {
+			return nil, fmt.Errorf("marshal request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := c.Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		defer res.Body.Close()
+		return nil, newResponseError(res)
+	}
+	return res, nil
+}
+
+func newResponseError(res *http.Response) error {
+	respErr := &ResponseError{
+		StatusCode: res.StatusCode,
+		Message:    http.StatusText(res.StatusCode),
+	}
+
+	var errRes api.ErrorResponse
+	if err := json.NewDecoder(res.Body).Decode(&errRes); err == nil &&
+		errRes.Error != "" {
+
+		respErr.Message = errRes.Error
+		respErr.PeerErrorCode = errRes.PeerErrorCode
+	}
+
+	seconds, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err == nil && seconds > 0 {
+		respErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return respErr
+}
+
+// pageQuery returns the query parameters of the list APIs. Zero values are
+// omitted so that the API uses its defaults.
+func pageQuery(page int, pageSize int) url.Values {
+	query := url.Values{}
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if pageSize > 0 {
+		query.Set("pageSize", strconv.Itoa(pageSize))
+	}
+	return query
+}
+
+// idPath returns a path of a resource with a numeric ID.
+func idPath(prefix string, id uint) string {
+	return prefix + "/" + strconv.FormatUint(uint64(id), 10)
+}
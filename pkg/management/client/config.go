@@ -0,0 +1,22 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// ReloadConfig makes the node read its config again. The response lists the
+// changed options that are applied and those that require a restart.
+func (c *Client) ReloadConfig(ctx context.Context) (
+	*api.ReloadConfigResponse, error) {
+
+	var res api.ReloadConfigResponse
+	if err := c.do(ctx, http.MethodPost, "/config/reload", nil, nil,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
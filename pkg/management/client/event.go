@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// dataPrefix is the prefix of the data lines of Server-Sent Events.
+var dataPrefix = []byte("data:")
+
+// EventStream receives events from the event stream API. It must be closed
+// when it is no longer used.
+type EventStream struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+// Events opens a stream of the node events of the specified types. All types
+// permitted to the token are received if no type is specified. The stream is
+// closed when the context is done.
+func (c *Client) Events(ctx context.Context, types ...string) (
+	*EventStream, error) {
+
+	query := url.Values{}
+	if len(types) > 0 {
+		query.Set("types", strings.Join(types, ","))
+	}
+
+	res, err := c.send(ctx, http.MethodGet, "/events", query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EventStream{
+		body:   res.Body,
+		reader: bufio.NewReader(res.Body),
+	}, nil
+}
+
+// Next blocks until the next event is received. It returns io.EOF when the
+// node ends the stream.
+func (s *EventStream) Next() (*api.Event, error) {
+	var data []byte
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("read event: %w", err)
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		// A blank line dispatches the event. Comments, such as
+		// heartbeats, and fields other than data are skipped since
+		// the data contains the whole event.
+		if len(line) == 0 {
+			if len(data) == 0 {
+				continue
+			}
+			var e api.Event
+			if err := json.Unmarshal(data, &e); err != nil {
+				return nil, fmt.Errorf("decode event: %w", err)
+			}
+			return &e, nil
+		}
+
+		if !bytes.HasPrefix(line, dataPrefix) {
+			continue
+		}
+		value := bytes.TrimPrefix(line[len(dataPrefix):], []byte(" "))
+		if len(data) > 0 {
+			data = append(data, '\n')
+		}
+		data = append(data, value...)
+	}
+}
+
+// Close closes the stream.
+func (s *EventStream) Close() error {
+	if err := s.body.Close(); err != nil {
+		return fmt.Errorf("close event stream: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// ListFriends returns the friends.
+func (c *Client) ListFriends(ctx context.Context) (
+	[]api.FriendResponse, error) {
+
+	var res api.ListFriendsResponse
+	if err := c.do(ctx, http.MethodGet, "/people", nil, nil,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return res.Friends, nil
+}
+
+// GetFriend returns the friend with the specified node ID.
+func (c *Client) GetFriend(ctx context.Context, nodeID string) (
+	*api.FriendResponse, error) {
+
+	var res api.FriendResponse
+	if err := c.do(ctx, http.MethodGet, friendPath(nodeID), nil, nil,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateFriend updates the friend with the specified node ID.
+func (c *Client) UpdateFriend(ctx context.Context, nodeID string,
+	req api.UpdateFriendRequest) (*api.FriendResponse, error) {
+
+	var res api.FriendResponse
+	if err := c.do(ctx, http.MethodPatch, friendPath(nodeID), nil, req,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RemoveFriend removes the friend with the specified node ID.
+func (c *Client) RemoveFriend(ctx context.Context, nodeID string) error {
+	return c.do(ctx, http.MethodDelete, friendPath(nodeID), nil, nil, nil)
+}
+
+// SendInvite sends a friend invite to the node with the identifier.
+func (c *Client) SendInvite(ctx context.Context, identifier string) error {
+	return c.do(ctx, http.MethodPost, "/people/invite/send", nil,
+		api.SendInviteRequest{Identifier: identifier}, nil)
+}
+
+// ListIncomingRequests returns the friend requests received by the node.
+func (c *Client) ListIncomingRequests(ctx context.Context) (
+	[]api.FriendRequestResponse, error) {
+
+	return c.listRequests(ctx, "incoming")
+}
+
+// ListOutgoingRequests returns the friend requests sent by the node.
+func (c *Client) ListOutgoingRequests(ctx context.Context) (
+	[]api.FriendRequestResponse, error) {
+
+	return c.listRequests(ctx, "outgoing")
+}
+
+func (c *Client) listRequests(ctx context.Context, direction string) (
+	[]api.FriendRequestResponse, error) {
+
+	var res api.ListFriendRequestsResponse
+	if err := c.do(ctx, http.MethodGet, "/people/requests/"+direction,
+		nil, nil, &res); err != nil {
+
+		return nil, err
+	}
+	return res.Requests, nil
+}
+
+// AcceptRequest accepts the incoming friend request from the node ID.
+func (c *Client) AcceptRequest(ctx context.Context, nodeID string) error {
+	return c.do(ctx, http.MethodPost, requestPath(nodeID, "accept"), nil,
+		nil, nil)
+}
+
+// DeclineRequest declines the incoming friend request from the node ID.
+func (c *Client) DeclineRequest(ctx context.Context, nodeID string) error {
+	return c.do(ctx, http.MethodPost, requestPath(nodeID, "decline"), nil,
+		nil, nil)
+}
+
+// CancelRequest cancels the outgoing friend request to the node ID.
+func (c *Client) CancelRequest(ctx context.Context, nodeID string) error {
+	return c.do(ctx, http.MethodPost, requestPath(nodeID, "cancel"), nil,
+		nil, nil)
+}
+
+func friendPath(nodeID string) string {
+	return "/people/" + url.PathEscape(nodeID)
+}
+
+func requestPath(nodeID string, action string) string {
+	return "/people/requests/" + url.PathEscape(nodeID) + "/" + action
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// SendLetter composes a letter and delivers it to a friend.
+func (c *Client) SendLetter(ctx context.Context, req api.SendLetterRequest) (
+	*api.LetterResponse, error) {
+
+	var res api.LetterResponse
+	if err := c.do(ctx, http.MethodPost, "/letters/send", nil, req,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListInbox returns a page of the received letters, newest first. Zero page or
+// pageSize uses the default.
+func (c *Client) ListInbox(ctx context.Context, page int, pageSize int) (
+	*api.ListLettersResponse, error) {
+
+	return c.listLetters(ctx, "/letters/inbox", page, pageSize)
+}
+
+// ListOutbox returns a page of the sent letters, newest first. Zero page or
+// pageSize uses the default.
+func (c *Client) ListOutbox(ctx context.Context, page int, pageSize int) (
+	*api.ListLettersResponse, error) {
+
+	return c.listLetters(ctx, "/letters/outbox", page, pageSize)
+}
+
+func (c *Client) listLetters(ctx context.Context, path string, page int,
+	pageSize int) (*api.ListLettersResponse, error) {
+
+	var res api.ListLettersResponse
+	if err := c.do(ctx, http.MethodGet, path, pageQuery(page, pageSize),
+		nil, &res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetLetter returns the letter with the specified ID.
+func (c *Client) GetLetter(ctx context.Context, id uint) (
+	*api.LetterResponse, error) {
+
+	var res api.LetterResponse
+	if err := c.do(ctx, http.MethodGet, idPath("/letters", id), nil, nil,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
+
+// MarkLetterRead marks the letter with the specified ID as read.
+func (c *Client) MarkLetterRead(ctx context.Context, id uint) (
+	*api.LetterResponse, error) {
+
+	return c.markLetter(ctx, id, "read")
+}
+
+// MarkLetterUnread marks the letter with the specified ID as unread.
+func (c *Client) MarkLetterUnread(ctx context.Context, id uint) (
+	*api.LetterResponse, error) {
+
+	return c.markLetter(ctx, id, "unread")
+}
+
+func (c *Client) markLetter(ctx context.Context, id uint, action string) (
+	*api.LetterResponse, error) {
+
+	var res api.LetterResponse
+	if err := c.do(ctx, http.MethodPost,
+		idPath("/letters", id)+"/"+action, nil, nil, &res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteLetter deletes the letter with the specified ID.
+func (c *Client) DeleteLetter(ctx context.Context, id uint) error {
+	return c.do(ctx, http.MethodDelete, idPath("/letters", id), nil, nil,
+		nil)
+}
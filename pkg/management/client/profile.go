@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// Profile returns the profile of the user that is shared with friends.
+func (c *Client) Profile(ctx context.Context) (*api.ProfileResponse, error) {
+	var res api.ProfileResponse
+	if err := c.do(ctx, http.MethodGet, "/profile", nil, nil,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateProfile changes the fields of the profile that are set in the
+// request. The new profile is sent to all friends.
+func (c *Client) UpdateProfile(ctx context.Context,
+	req api.UpdateProfileRequest) (*api.ProfileResponse, error) {
+
+	var res api.ProfileResponse
+	if err := c.do(ctx, http.MethodPatch, "/profile", nil, req,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// Login logs in with the administrator password. On success, the access
+// token of the new session is used for subsequent requests.
+func (c *Client) Login(ctx context.Context, password string) (
+	*api.ManagementLoginResponse, error) {
+
+	var res api.ManagementLoginResponse
+	req := api.ManagementLoginRequest{Password: password}
+	if err := c.do(ctx, http.MethodPost, "/login", nil, req,
+		&res); err != nil {
+
+		return nil, err
+	}
+	c.SetToken(res.AccessToken)
+	return &res, nil
+}
+
+// Setup sets up the administrator password on the first run. On success, the
+// access token of the new session is used for subsequent requests.
+func (c *Client) Setup(ctx context.Context, password string) (
+	*api.ManagementLoginResponse, error) {
+
+	var res api.ManagementLoginResponse
+	req := api.ManagementSetupRequest{Password: password}
+	if err := c.do(ctx, http.MethodPost, "/setup", nil, req,
+		&res); err != nil {
+
+		return nil, err
+	}
+	c.SetToken(res.AccessToken)
+	return &res, nil
+}
+
+// Logout revokes the current login session.
+func (c *Client) Logout(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/logout", nil, nil, nil)
+}
+
+// Refresh extends the expiry of the current login session.
+func (c *Client) Refresh(ctx context.Context) (*api.SessionResponse, error) {
+	var res api.SessionResponse
+	if err := c.do(ctx, http.MethodPost, "/refresh", nil, nil,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Identity returns the identifier of the node for other people to connect.
+func (c *Client) Identity(ctx context.Context) (string, error) {
+	var res api.IdentityResponse
+	if err := c.do(ctx, http.MethodGet, "/identity", nil, nil,
+		&res); err != nil {
+
+		return "", err
+	}
+	return res.Identifier, nil
+}
+
+// ListSessions returns the login sessions.
+func (c *Client) ListSessions(ctx context.Context) (
+	[]api.SessionResponse, error) {
+
+	var res api.ListSessionsResponse
+	if err := c.do(ctx, http.MethodGet, "/sessions", nil, nil,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return res.Sessions, nil
+}
+
+// RevokeSession revokes the login session with the specified ID.
+func (c *Client) RevokeSession(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/sessions/"+url.PathEscape(id),
+		nil, nil, nil)
+}
+
+// RevokeOtherSessions revokes all login sessions except the current one and
+// returns the number of revoked sessions.
+func (c *Client) RevokeOtherSessions(ctx context.Context) (int, error) {
+	var res api.RevokeSessionsResponse
+	if err := c.do(ctx, http.MethodDelete, "/sessions", nil, nil,
+		&res); err != nil {
+
+		return 0, err
+	}
+	return res.Revoked, nil
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// CreateToken creates an API token. The token is only returned here.
+func (c *Client) CreateToken(ctx context.Context,
+	req api.CreateTokenRequest) (*api.CreateTokenResponse, error) {
+
+	var res api.CreateTokenResponse
+	if err := c.do(ctx, http.MethodPost, "/tokens", nil, req,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListTokens returns the API tokens.
+func (c *Client) ListTokens(ctx context.Context) ([]api.TokenResponse, error) {
+	var res api.ListTokensResponse
+	if err := c.do(ctx, http.MethodGet, "/tokens", nil, nil,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return res.Tokens, nil
+}
+
+// RevokeToken revokes the API token with the specified ID.
+func (c *Client) RevokeToken(ctx context.Context, id uint) error {
+	return c.do(ctx, http.MethodDelete, idPath("/tokens", id), nil, nil,
+		nil)
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sunboyy/lettered/pkg/management/api"
+)
+
+// CreateWebhook registers a webhook. The secret for verifying the payloads is
+// only returned here.
+func (c *Client) CreateWebhook(ctx context.Context,
+	req api.CreateWebhookRequest) (*api.CreateWebhookResponse, error) {
+
+	var res api.CreateWebhookResponse
+	if err := c.do(ctx, http.MethodPost, "/webhooks", nil, req,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListWebhooks returns the webhooks.
+func (c *Client) ListWebhooks(ctx context.Context) (
+	[]api.WebhookResponse, error) {
+
+	var res api.ListWebhooksResponse
+	if err := c.do(ctx, http.MethodGet, "/webhooks", nil, nil,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return res.Webhooks, nil
+}
+
+// GetWebhook returns the webhook with the specified ID.
+func (c *Client) GetWebhook(ctx context.Context, id uint) (
+	*api.WebhookResponse, error) {
+
+	var res api.WebhookResponse
+	if err := c.do(ctx, http.MethodGet, idPath("/webhooks", id), nil, nil,
+		&res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteWebhook deletes the webhook with the specified ID and its delivery
+// log.
+func (c *Client) DeleteWebhook(ctx context.Context, id uint) error {
+	return c.do(ctx, http.MethodDelete, idPath("/webhooks", id), nil, nil,
+		nil)
+}
+
+// ListWebhookDeliveries returns a page of the delivery log of the webhook with
+// the specified ID, newest first. Zero page or pageSize uses the default.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, id uint, page int,
+	pageSize int) (*api.ListWebhookDeliveriesResponse, error) {
+
+	var res api.ListWebhookDeliveriesResponse
+	if err := c.do(ctx, http.MethodGet,
+		idPath("/webhooks", id)+"/deliveries",
+		pageQuery(page, pageSize), nil, &res); err != nil {
+
+		return nil, err
+	}
+	return &res, nil
+}
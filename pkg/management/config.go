@@ -6,18 +6,47 @@ type Config struct {
 	Port int
 
 	// Password is the secret key for logging in to the management console.
+	// It is only used to set up the password on the first start, after
+	// which the password is changed with the passwd command. Leave it empty
+	// to set up the password via the setup API instead.
 	Password string
 
 	// SessionTimeout is the duration (in seconds) that the access token for
-	// authenticaing to the management console can be used.
+	// authenticaing to the management console can be used. The duration is
+	// extended when the session is refreshed.
 	SessionTimeout int
+
+	// PersistSessions specifies whether the sessions are stored in the
+	// database so that they survive a restart.
+	PersistSessions bool
+
+	// LoginAttempts is the number of failed login attempts from a client
+	// IP before the client IP is locked out. Zero disables the lockout.
+	LoginAttempts int
+
+	// GlobalLoginAttempts is the number of failed login attempts from all
+	// client IPs before logging in is locked out for everyone. Zero
+	// disables the lockout.
+	GlobalLoginAttempts int
+
+	// LoginLockout is the duration (in seconds) of the first lockout. The
+	// duration doubles on every further failed attempt.
+	LoginLockout int
+
+	// MaxLoginLockout is the maximum duration (in seconds) of a lockout.
+	// Failed attempts are forgotten after no failed attempt for this
+	// duration.
+	MaxLoginLockout int
 }
 
 // DefaultConfig returns all default values for the Config struct.
 func DefaultConfig() Config {
 	return Config{
-		Port:           11926,
-		Password:       "letteradm",
-		SessionTimeout: 3600,
+		Port:                11926,
+		SessionTimeout:      3600,
+		LoginAttempts:       5,
+		GlobalLoginAttempts: 50,
+		LoginLockout:        30,
+		MaxLoginLockout:     3600,
 	}
 }
@@ -0,0 +1,234 @@
+package management
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/db"
+)
+
+// lastUseResolution is the minimum duration between two updates of the last
+// use of a session. It limits the database writes of persisted sessions.
+const lastUseResolution = time.Minute
+
+// Authenticate returns the session of the access token if it is active. The
+// last use and the client IP of the session are updated.
+func (a *Auth) Authenticate(accessToken string, clientIP string) (*db.Session,
+	bool) {
+
+	a.sessionMu.Lock()
+	defer a.sessionMu.Unlock()
+
+	tokenHash := hashAccessToken(accessToken)
+	session, ok := a.sessions[tokenHash]
+	if !ok {
+		return nil, false
+	}
+
+	now := time.Now()
+	if !now.Before(session.ExpiresAt) {
+		a.deleteSession(tokenHash)
+		return nil, false
+	}
+
+	if now.Sub(session.LastUsedAt) >= lastUseResolution ||
+		session.ClientIP != clientIP {
+
+		session.LastUsedAt = now
+		session.ClientIP = clientIP
+		a.persistSession(session)
+	}
+
+	sessionCopy := *session
+	return &sessionCopy, true
+}
+
+// Refresh extends the expiration of the session with the specified ID by the
+// session timeout from now.
+func (a *Auth) Refresh(id string) (*db.Session, error) {
+	a.sessionMu.Lock()
+	defer a.sessionMu.Unlock()
+
+	_, session, ok := a.findSession(id)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	session.ExpiresAt = time.Now().Add(a.sessionTimeout())
+	a.persistSession(session)
+
+	sessionCopy := *session
+	return &sessionCopy, nil
+}
+
+// ListSessions returns all active sessions, the newest first.
+func (a *Auth) ListSessions() []db.Session {
+	a.sessionMu.Lock()
+	defer a.sessionMu.Unlock()
+
+	a.deleteExpiredSessions()
+
+	sessions := make([]db.Session, 0, len(a.sessions))
+	for _, session := range a.sessions {
+		sessions = append(sessions, *session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions
+}
+
+// RevokeSession revokes the session with the specified ID. The access token of
+// the session cannot be used afterwards.
+func (a *Auth) RevokeSession(id string) error {
+	a.sessionMu.Lock()
+	defer a.sessionMu.Unlock()
+
+	tokenHash, _, ok := a.findSession(id)
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	a.deleteSession(tokenHash)
+	return nil
+}
+
+// RevokeOtherSessions revokes all sessions except the one with the specified
+// ID and returns the number of revoked sessions.
+func (a *Auth) RevokeOtherSessions(id string) int {
+	a.sessionMu.Lock()
+	defer a.sessionMu.Unlock()
+
+	count := 0
+	for tokenHash, session := range a.sessions {
+		if session.ID != id {
+			a.deleteSession(tokenHash)
+			count++
+		}
+	}
+	return count
+}
+
+func (a *Auth) createSession(clientIP string) (string, *db.Session, error) {
+	accessToken, err := generateAccessToken()
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	session := &db.Session{
+		ID:         id,
+		TokenHash:  hashAccessToken(accessToken),
+		ClientIP:   clientIP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(a.sessionTimeout()),
+	}
+
+	if a.config.PersistSessions {
+		if err := a.db.CreateSession(session); err != nil {
+			return "", nil, fmt.Errorf("create session: %w", err)
+		}
+	}
+
+	a.sessionMu.Lock()
+	defer a.sessionMu.Unlock()
+
+	a.deleteExpiredSessions()
+	a.sessions[session.TokenHash] = session
+
+	sessionCopy := *session
+	return accessToken, &sessionCopy, nil
+}
+
+// loadSessions restores the persisted sessions that have not expired.
+func (a *Auth) loadSessions() error {
+	if !a.config.PersistSessions {
+		return nil
+	}
+
+	now := time.Now()
+	if err := a.db.DeleteExpiredSessions(now); err != nil {
+		return fmt.Errorf("delete expired sessions: %w", err)
+	}
+
+	sessions, err := a.db.ListSessions(now)
+	if err != nil {
+		return fmt.Errorf("list sessions: %w", err)
+	}
+	for i := range sessions {
+		a.sessions[sessions[i].TokenHash] = &sessions[i]
+	}
+	return nil
+}
+
+// findSession returns the session with the specified ID together with the
+// hash of its access token. sessionMu must be held.
+func (a *Auth) findSession(id string) (string, *db.Session, bool) {
+	now := time.Now()
+	for tokenHash, session := range a.sessions {
+		if session.ID == id && now.Before(session.ExpiresAt) {
+			return tokenHash, session, true
+		}
+	}
+	return "", nil, false
+}
+
+// deleteExpiredSessions deletes the sessions that have expired. sessionMu must
+// be held.
+func (a *Auth) deleteExpiredSessions() {
+	now := time.Now()
+	for tokenHash, session := range a.sessions {
+		if !now.Before(session.ExpiresAt) {
+			a.deleteSession(tokenHash)
+		}
+	}
+}
+
+// deleteSession deletes a session by the hash of its access token. sessionMu
+// must be held.
+func (a *Auth) deleteSession(tokenHash string) {
+	session, ok := a.sessions[tokenHash]
+	if !ok {
+		return
+	}
+	delete(a.sessions, tokenHash)
+
+	if a.config.PersistSessions {
+		if err := a.db.DeleteSession(session.ID); err != nil {
+			log.Error().Err(err).Msg("management: error deleting " +
+				"session")
+		}
+	}
+}
+
+// persistSession stores the changes of a session if sessions are persisted.
+// Failures are only logged since the session is still valid in memory.
+func (a *Auth) persistSession(session *db.Session) {
+	if !a.config.PersistSessions {
+		return
+	}
+	if err := a.db.UpdateSession(session); err != nil {
+		log.Error().Err(err).Msg("management: error updating session")
+	}
+}
+
+func (a *Auth) sessionTimeout() time.Duration {
+	a.configMu.RLock()
+	defer a.configMu.RUnlock()
+
+	return time.Duration(a.config.SessionTimeout) * time.Second
+}
+
+func hashAccessToken(accessToken string) string {
+	hash := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,143 @@
+package management
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sunboyy/lettered/pkg/backoff"
+)
+
+// LockedOutError is returned on Login when logging in is locked out because
+// of too many failed attempts.
+type LockedOutError struct {
+	// RetryAfter is the remaining duration of the lockout.
+	RetryAfter time.Duration
+}
+
+func (e *LockedOutError) Error() string {
+	return "too many failed login attempts"
+}
+
+// loginThrottle counts failed login attempts per client IP and globally. After
+// the maximum number of attempts, further attempts are locked out for a
+// duration that doubles on every failed attempt up to the maximum lockout.
+// The counters are reset after a successful login or after no failed attempt
+// for the maximum lockout.
+type loginThrottle struct {
+	// mu guards all fields.
+	mu sync.Mutex
+
+	maxAttempts       int
+	globalMaxAttempts int
+	lockout           time.Duration
+	maxLockout        time.Duration
+
+	perIP    map[string]*attemptCounter
+	globally attemptCounter
+}
+
+type attemptCounter struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func newLoginThrottle(config Config) *loginThrottle {
+	t := &loginThrottle{
+		perIP: map[string]*attemptCounter{},
+	}
+	t.update(config)
+	return t
+}
+
+// update applies the lockout options of the config. Current lockouts are kept
+// until they end.
+func (t *loginThrottle) update(config Config) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.maxAttempts = config.LoginAttempts
+	t.globalMaxAttempts = config.GlobalLoginAttempts
+	t.lockout = time.Duration(config.LoginLockout) * time.Second
+	t.maxLockout = time.Duration(config.MaxLoginLockout) * time.Second
+}
+
+// check returns the remaining lockout duration of the client IP, or zero if
+// the client IP is allowed to attempt logging in.
+func (t *loginThrottle) check(clientIP string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lockedUntil := t.globally.lockedUntil
+	if counter, ok := t.perIP[clientIP]; ok &&
+		counter.lockedUntil.After(lockedUntil) {
+
+		lockedUntil = counter.lockedUntil
+	}
+
+	retryAfter := time.Until(lockedUntil)
+
+	if retryAfter < 0 {
+		return 0
+	}
+	return retryAfter
+}
+
+// fail records a failed attempt of the client IP and returns the number of
+// consecutive failed attempts of the client IP.
+func (t *loginThrottle) fail(clientIP string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.prune(now)
+
+	counter, ok := t.perIP[clientIP]
+	if !ok {
+		counter = &attemptCounter{}
+		t.perIP[clientIP] = counter
+	}
+
+	t.record(counter, t.maxAttempts, now)
+	t.record(&t.globally, t.globalMaxAttempts, now)
+	return counter.failures
+}
+
+// succeed resets the counters after a successful login of the client IP.
+func (t *loginThrottle) succeed(clientIP string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.perIP, clientIP)
+	t.globally = attemptCounter{}
+}
+
+func (t *loginThrottle) record(counter *attemptCounter, maxAttempts int,
+	now time.Time) {
+
+	if now.Sub(counter.lastFailure) >= t.maxLockout {
+		*counter = attemptCounter{}
+	}
+
+	counter.failures++
+	counter.lastFailure = now
+
+	// Zero disables the lockout.
+	if maxAttempts <= 0 || counter.failures < maxAttempts {
+		return
+	}
+
+	lockout := backoff.Exponential(t.lockout, t.maxLockout,
+		counter.failures-maxAttempts+1)
+	counter.lockedUntil = now.Add(lockout)
+}
+
+// prune deletes the counters of client IPs that have not failed for the
+// maximum lockout.
+func (t *loginThrottle) prune(now time.Time) {
+	for clientIP, counter := range t.perIP {
+		if now.Sub(counter.lastFailure) >= t.maxLockout {
+			delete(t.perIP, clientIP)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package management
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/db"
+)
+
+// Scope is a permission granted to an API token.
+type Scope string
+
+const (
+	// ScopePeopleRead allows reading friends, friend requests and blocks.
+	ScopePeopleRead Scope = "people:read"
+
+	// ScopePeopleWrite allows inviting, removing and blocking peers and
+	// managing friend requests.
+	ScopePeopleWrite Scope = "people:write"
+
+	// ScopeLettersRead allows reading letters.
+	ScopeLettersRead Scope = "letters:read"
+
+	// ScopeLettersSend allows sending letters.
+	ScopeLettersSend Scope = "letters:send"
+
+	// ScopeLettersWrite allows marking and deleting letters.
+	ScopeLettersWrite Scope = "letters:write"
+
+	// ScopeAdmin allows everything, including managing sessions and API
+	// tokens.
+	ScopeAdmin Scope = "admin"
+)
+
+// apiTokenPrefix distinguishes API tokens from session access tokens.
+const apiTokenPrefix = "lt_"
+
+// Scopes are all scopes that can be granted to an API token.
+var Scopes = []Scope{
+	ScopePeopleRead,
+	ScopePeopleWrite,
+	ScopeLettersRead,
+	ScopeLettersSend,
+	ScopeLettersWrite,
+	ScopeAdmin,
+}
+
+var (
+	// ErrEmptyTokenName is returned when creating an API token without a
+	// name.
+	ErrEmptyTokenName = errors.New("token name is empty")
+
+	// ErrNoScope is returned when creating an API token without scopes.
+	ErrNoScope = errors.New("token has no scope")
+
+	// ErrInvalidScope is returned when creating an API token with an
+	// unknown scope.
+	ErrInvalidScope = errors.New("invalid scope")
+
+	// ErrExpiredToken is returned when creating an API token that expires
+	// in the past.
+	ErrExpiredToken = errors.New("token expires in the past")
+
+	// ErrTokenNotFound is returned when there is no API token with the
+	// specified ID.
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// IsAPIToken reports whether the bearer token is an API token rather than a
+// session access token.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, apiTokenPrefix)
+}
+
+// TokenScopes returns the scopes granted to an API token.
+func TokenScopes(token *db.APIToken) []Scope {
+	fields := strings.Fields(token.Scopes)
+	scopes := make([]Scope, 0, len(fields))
+	for _, field := range fields {
+		scopes = append(scopes, Scope(field))
+	}
+	return scopes
+}
+
+// HasScope reports whether an API token is granted the scope. The admin scope
+// implies all scopes.
+func HasScope(token *db.APIToken, scope Scope) bool {
+	for _, granted := range TokenScopes(token) {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIToken creates a named API token with the scopes and returns the
+// token. The token is only available here since only its hash is stored. If
+// expiresAt is nil, the token never expires.
+func (a *Auth) CreateAPIToken(name string, scopes []Scope,
+	expiresAt *time.Time) (string, *db.APIToken, error) {
+
+	if name == "" {
+		return "", nil, ErrEmptyTokenName
+	}
+	if len(scopes) == 0 {
+		return "", nil, ErrNoScope
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return "", nil, fmt.Errorf("%w: %s", ErrInvalidScope,
+				scope)
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", nil, ErrExpiredToken
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", nil, err
+	}
+	token := apiTokenPrefix + secret
+
+	scopeStrings := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scopeStrings = append(scopeStrings, string(scope))
+	}
+
+	apiToken := &db.APIToken{
+		Name:      name,
+		TokenHash: hashAccessToken(token),
+		Scopes:    strings.Join(scopeStrings, " "),
+		ExpiresAt: expiresAt,
+	}
+	if err := a.db.CreateAPIToken(apiToken); err != nil {
+		return "", nil, fmt.Errorf("create api token: %w", err)
+	}
+
+	return token, apiToken, nil
+}
+
+// ListAPITokens returns all API tokens, including the expired ones.
+func (a *Auth) ListAPITokens() ([]db.APIToken, error) {
+	tokens, err := a.db.ListAPITokens()
+	if err != nil {
+		return nil, fmt.Errorf("list api tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// RevokeAPIToken deletes the API token with the specified ID.
+func (a *Auth) RevokeAPIToken(id uint) error {
+	ok, err := a.db.DeleteAPIToken(id)
+	if err != nil {
+		return fmt.Errorf("delete api token %d: %w", id, err)
+	}
+	if !ok {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIToken returns the API token if it exists and has not expired.
+// The last use of the token is updated.
+func (a *Auth) AuthenticateAPIToken(token string) (*db.APIToken, bool) {
+	apiToken, err := a.db.FindAPITokenByHash(hashAccessToken(token))
+	if err != nil {
+		log.Error().Err(err).Msg("management: error finding api token")
+		return nil, false
+	}
+	if apiToken == nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	if apiToken.ExpiresAt != nil && !now.Before(*apiToken.ExpiresAt) {
+		return nil, false
+	}
+
+	if apiToken.LastUsedAt == nil ||
+		now.Sub(*apiToken.LastUsedAt) >= lastUseResolution {
+
+		apiToken.LastUsedAt = &now
+		if err := a.db.UpdateAPIToken(apiToken); err != nil {
+			log.Error().Err(err).
+				Msg("management: error updating api token")
+		}
+	}
+
+	return apiToken, true
+}
+
+func validScope(scope Scope) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
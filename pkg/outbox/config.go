@@ -0,0 +1,27 @@
+package outbox
+
+// Config defines the configuration options for delivering outgoing P2P
+// messages to peers that are unreachable.
+type Config struct {
+	// RetryInterval is the duration (in seconds) to wait before retrying
+	// the first failed delivery. The interval is doubled after each
+	// subsequent failure.
+	RetryInterval int
+
+	// MaxRetryInterval is the maximum duration (in seconds) between two
+	// delivery attempts.
+	MaxRetryInterval int
+
+	// MaxAge is the duration (in seconds) after which an undelivered
+	// message is discarded.
+	MaxAge int
+}
+
+// DefaultConfig returns all default values for the Config struct.
+func DefaultConfig() Config {
+	return Config{
+		RetryInterval:    30,
+		MaxRetryInterval: 3600,
+		MaxAge:           604800,
+	}
+}
@@ -0,0 +1,309 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/backoff"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/p2p"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// pollInterval is the duration between two checks for due messages.
+	pollInterval = 10 * time.Second
+
+	// batchSize is the maximum number of messages processed in each check.
+	batchSize = 50
+
+	// maxConcurrentPeers is the maximum number of peers to which messages
+	// are delivered at the same time.
+	maxConcurrentPeers = 8
+)
+
+// errInvalidIdentifier is an error indicating that the identifier cannot be
+// extracted because of an unexpected pattern.
+var errInvalidIdentifier = errors.New("invalid identifier")
+
+// DeliveredFunc is called after a message is delivered to the peer. It
+// receives the response body returned by the peer.
+type DeliveredFunc func(msg *db.OutboxMessage, res []byte) error
+
+// FailedFunc is called when a message is discarded without being delivered,
+// either because the peer rejects it or because it reaches the maximum age.
+type FailedFunc func(msg *db.OutboxMessage) error
+
+// Handler defines the callbacks of the messages of a P2P event. Any of the
+// callbacks can be nil.
+type Handler struct {
+	Delivered DeliveredFunc
+	Failed    FailedFunc
+}
+
+// Worker is a background worker that delivers outgoing P2P messages stored in
+// the database. Failed deliveries are retried with exponential backoff until
+// the message reaches the maximum age.
+type Worker struct {
+	config     Config
+	db         *db.DB
+	p2pClient  *p2p.Client
+	handlerMap map[string]Handler
+
+	// wakeCh notifies the worker that a new message is enqueued.
+	wakeCh chan struct{}
+}
+
+// NewWorker is a constructor of Worker.
+func NewWorker(config Config, db *db.DB, p2pClient *p2p.Client) *Worker {
+	return &Worker{
+		config:     config,
+		db:         db,
+		p2pClient:  p2pClient,
+		handlerMap: map[string]Handler{},
+		wakeCh:     make(chan struct{}, 1),
+	}
+}
+
+// On registers callbacks for the messages of the specified event.
+func (w *Worker) On(event string, handler Handler) {
+	w.handlerMap[event] = handler
+}
+
+// Enqueue stores a message to be delivered to the peer with the specified
+// identifier and wakes the worker up to deliver it.
+func (w *Worker) Enqueue(identifier string, event string,
+	body protoreflect.ProtoMessage) error {
+
+	nodeID, _, ok := p2p.ExtractIdentifier(identifier)
+	if !ok {
+		return errInvalidIdentifier
+	}
+
+	bodyBytes, err := proto.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal body proto: %w", err)
+	}
+
+	if err := w.db.CreateOutboxMessage(&db.OutboxMessage{
+		NodeID:        nodeID,
+		Identifier:    identifier,
+		Event:         event,
+		Body:          bodyBytes,
+		NextAttemptAt: time.Now(),
+	}); err != nil {
+		return fmt.Errorf("create outbox message: %w", err)
+	}
+
+	select {
+	case w.wakeCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Cancel discards all undelivered messages of the specified event to the
+// node ID.
+func (w *Worker) Cancel(nodeID string, event string) error {
+	if err := w.db.DeleteOutboxMessages(nodeID, event); err != nil {
+		return fmt.Errorf("delete outbox messages %s: %w", nodeID, err)
+	}
+	return nil
+}
+
+// Redirect sends the undelivered messages to the node ID to the specified
+// identifier instead, e.g. after the peer announces a new hostname. The
+// messages are attempted again immediately.
+func (w *Worker) Redirect(nodeID string, identifier string) error {
+	if err := w.db.RedirectOutboxMessages(nodeID, identifier,
+		time.Now()); err != nil {
+
+		return fmt.Errorf("redirect outbox messages %s: %w", nodeID,
+			err)
+	}
+
+	select {
+	case w.wakeCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run starts delivering messages. It blocks until the context is done.
+// Deliveries interrupted by the context are kept in the outbox and retried on
+// the next run.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.processDueMessages(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wakeCh:
+		}
+	}
+}
+
+// processDueMessages delivers the due messages to different peers
+// concurrently, so that an unreachable peer does not hold up the delivery to
+// the others. The messages to the same peer are delivered in order.
+func (w *Worker) processDueMessages(ctx context.Context) {
+	msgs, err := w.db.ListDueOutboxMessages(time.Now(), batchSize)
+	if err != nil {
+		log.Error().Err(err).Msg("outbox: error listing due messages")
+		return
+	}
+
+	var nodeIDs []string
+	msgsByNodeID := map[string][]*db.OutboxMessage{}
+	for i := range msgs {
+		nodeID := msgs[i].NodeID
+		if _, ok := msgsByNodeID[nodeID]; !ok {
+			nodeIDs = append(nodeIDs, nodeID)
+		}
+		msgsByNodeID[nodeID] = append(msgsByNodeID[nodeID], &msgs[i])
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	sem := make(chan struct{}, maxConcurrentPeers)
+	for _, nodeID := range nodeIDs {
+		select {
+		case <-ctx.Done():
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+
+		peerMsgs := msgsByNodeID[nodeID]
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			w.processPeerMessages(ctx, peerMsgs)
+		}()
+	}
+}
+
+// processPeerMessages delivers the messages to a peer in order. The rest of
+// the messages are left for the next check after a temporary failure since
+// the peer is likely to be unreachable.
+func (w *Worker) processPeerMessages(ctx context.Context,
+	msgs []*db.OutboxMessage) {
+
+	for _, msg := range msgs {
+		if ctx.Err() != nil || !w.process(ctx, msg) {
+			return
+		}
+	}
+}
+
+// process attempts to deliver a message. The message is removed from the
+// outbox after it is delivered, rejected by the peer or expired. Otherwise,
+// the next attempt is scheduled. It returns false if the delivery fails
+// temporarily.
+func (w *Worker) process(ctx context.Context, msg *db.OutboxMessage) bool {
+	handler := w.handlerMap[msg.Event]
+
+	res, err := w.deliver(ctx, msg)
+	if err == nil {
+		if handler.Delivered != nil {
+			if err := handler.Delivered(msg, res); err != nil {
+				log.Error().Err(err).Msgf(
+					"outbox: error handling delivered %s "+
+						"to %s", msg.Event, msg.NodeID)
+			}
+		}
+		w.remove(msg)
+		return true
+	}
+
+	// The delivery is interrupted by shutting down. It is not counted as
+	// an attempt.
+	if ctx.Err() != nil {
+		return false
+	}
+
+	msg.Attempts++
+	msg.LastError = err.Error()
+	log.Debug().Err(err).Msgf("outbox: failed delivering %s to %s "+
+		"(attempt %d)", msg.Event, msg.NodeID, msg.Attempts)
+
+	if !p2p.IsTemporary(err) {
+		log.Warn().Err(err).Msgf("outbox: %s rejected by %s",
+			msg.Event, msg.NodeID)
+		w.fail(msg, handler)
+		return true
+	}
+
+	if time.Since(msg.CreatedAt) >= w.maxAge() {
+		log.Warn().Msgf("outbox: discarding %s to %s after %d attempts",
+			msg.Event, msg.NodeID, msg.Attempts)
+		w.fail(msg, handler)
+		return false
+	}
+
+	msg.NextAttemptAt = time.Now().Add(backoff.Exponential(
+		time.Duration(w.config.RetryInterval)*time.Second,
+		time.Duration(w.config.MaxRetryInterval)*time.Second,
+		msg.Attempts))
+	if err := w.db.UpdateOutboxMessage(msg); err != nil {
+		log.Error().Err(err).Msg("outbox: error updating message")
+	}
+	return false
+}
+
+// deliver sends the message to the peer if the peer advertises the event of the
+// message. Otherwise, it fails with ErrUnknownEvent without sending the message
+// so that the message is discarded.
+func (w *Worker) deliver(ctx context.Context, msg *db.OutboxMessage) ([]byte,
+	error) {
+
+	capabilities, err := w.p2pClient.Capabilities(ctx, msg.Identifier)
+	if err != nil {
+		return nil, fmt.Errorf("get capabilities: %w", err)
+	}
+	if !capabilities.Supports(msg.Event) {
+		return nil, fmt.Errorf("peer version %d: %w",
+			capabilities.Version, p2p.ErrUnknownEvent)
+	}
+
+	res, err := w.p2pClient.RequestRaw(ctx, msg.Identifier, msg.Event,
+		msg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("request: %w", err)
+	}
+	return res, nil
+}
+
+func (w *Worker) fail(msg *db.OutboxMessage, handler Handler) {
+	if handler.Failed != nil {
+		if err := handler.Failed(msg); err != nil {
+			log.Error().Err(err).Msgf(
+				"outbox: error handling failed %s to %s",
+				msg.Event, msg.NodeID)
+		}
+	}
+	w.remove(msg)
+}
+
+func (w *Worker) remove(msg *db.OutboxMessage) {
+	if err := w.db.DeleteOutboxMessage(msg.ID); err != nil {
+		log.Error().Err(err).Msg("outbox: error deleting message")
+	}
+}
+
+func (w *Worker) maxAge() time.Duration {
+	return time.Duration(w.config.MaxAge) * time.Second
+}
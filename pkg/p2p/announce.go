@@ -0,0 +1,124 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// hostnameUpdateContext is prepended to the signed content of hostname
+// announcements so that the signatures cannot be used for other purposes.
+const hostnameUpdateContext = "lettered hostname update"
+
+var (
+	// errNoPeerCert is an error indicating that the context of the request
+	// does not carry the certificate of the peer.
+	errNoPeerCert = errors.New("no peer certificate")
+
+	// errInvalidSignature is an error indicating that the signature of an
+	// announcement is not made by the peer.
+	errInvalidSignature = errors.New("invalid signature")
+)
+
+// peerCertKey is the context key of the certificate of the peer that sends the
+// request.
+type peerCertKey struct{}
+
+// withPeerCert returns a copy of the context carrying the certificate of the
+// peer.
+func withPeerCert(ctx context.Context,
+	cert *x509.Certificate) context.Context {
+
+	return context.WithValue(ctx, peerCertKey{}, cert)
+}
+
+// PeerCertificate returns the certificate presented by the peer in the TLS
+// handshake of the connection on which the request is received. It is
+// available in the context passed to handlers.
+func PeerCertificate(ctx context.Context) (*x509.Certificate, bool) {
+	cert, ok := ctx.Value(peerCertKey{}).(*x509.Certificate)
+	return cert, ok
+}
+
+// NewHostnameUpdate creates an announcement of the hostname of the user signed
+// with the client certificate. The timestamp is the current Unix time in
+// milliseconds.
+func (c *Client) NewHostnameUpdate(hostname string) (*HostnameUpdateRequest,
+	error) {
+
+	priv, ok := c.cert.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errUnsupportedPrivateKey
+	}
+	nodeID, err := NodeIDFromPubKey(priv.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Now().UnixMilli()
+	digest := hostnameUpdateDigest(nodeID, hostname, timestamp)
+	signature, err := ecdsa.SignASN1(rand.Reader, priv, digest)
+	if err != nil {
+		return nil, fmt.Errorf("sign hostname update: %w", err)
+	}
+
+	return &HostnameUpdateRequest{
+		Hostname:  hostname,
+		Timestamp: timestamp,
+		Signature: signature,
+	}, nil
+}
+
+// VerifyHostnameUpdate checks that the announcement is signed for the node ID
+// by the key of the certificate that the peer presents on the connection. The
+// context must be the one passed to the handler.
+func VerifyHostnameUpdate(ctx context.Context, nodeID string,
+	req *HostnameUpdateRequest) error {
+
+	cert, ok := PeerCertificate(ctx)
+	if !ok {
+		return errNoPeerCert
+	}
+
+	certNodeID, err := NodeIDFromPubKey(cert.PublicKey)
+	if err != nil {
+		return err
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || certNodeID != nodeID {
+		return errInvalidSignature
+	}
+
+	digest := hostnameUpdateDigest(nodeID, req.GetHostname(),
+		req.GetTimestamp())
+	if !ecdsa.VerifyASN1(pub, digest, req.GetSignature()) {
+		return errInvalidSignature
+	}
+	return nil
+}
+
+// hostnameUpdateDigest returns the SHA-256 hash of the signed content of a
+// hostname announcement. Variable-length fields are prefixed with their
+// lengths so that the encoding is unambiguous.
+func hostnameUpdateDigest(nodeID string, hostname string,
+	timestamp int64) []byte {
+
+	h := sha256.New()
+	var n [8]byte
+	for _, field := range []string{
+		hostnameUpdateContext, nodeID, hostname,
+	} {
+		binary.BigEndian.PutUint32(n[:4], uint32(len(field)))
+		h.Write(n[:4])
+		h.Write([]byte(field))
+	}
+	binary.BigEndian.PutUint64(n[:], uint64(timestamp))
+	h.Write(n[:])
+	return h.Sum(nil)
+}
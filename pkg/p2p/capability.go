@@ -0,0 +1,166 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ProtocolVersion is the version of the P2P protocol implemented by this
+// package. It is sent in the header of every request and in the HELLO
+// handshake.
+//
+// Version 1 introduces the response envelope. Version 2 introduces the HELLO
+// event for advertising capabilities.
+const ProtocolVersion = 2
+
+// envelopeVersion is the first protocol version in which responses are wrapped
+// in the Response envelope.
+const envelopeVersion = 1
+
+// capabilitiesTTL is the duration that the capabilities of a peer are cached
+// by the client.
+const capabilitiesTTL = 10 * time.Minute
+
+// legacyCapabilities are the events supported by peers that do not implement
+// the HELLO event, by protocol version.
+var legacyCapabilities = map[uint32][]string{
+	0: {
+		EventPing,
+		EventFriendInvite,
+	},
+	1: {
+		EventPing,
+		EventFriendInvite,
+		EventFriendRemove,
+		EventLetterSend,
+	},
+}
+
+// Capabilities describes the protocol version and the events supported by
+// a peer.
+type Capabilities struct {
+	Version uint32
+	Events  []string
+}
+
+// Supports reports whether the peer can handle the specified event.
+func (c *Capabilities) Supports(event string) bool {
+	for _, e := range c.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities returns the capabilities of the peer with the specified
+// identifier. The result is cached per node ID. Peers that do not implement
+// the HELLO event are assumed to support the legacy events of their version:
+// version 1 if they reject the event in the response envelope, or version 0
+// if they close the connection without a response.
+func (c *Client) Capabilities(ctx context.Context, identifier string) (
+	*Capabilities, error) {
+
+	nodeID, _, ok := ExtractIdentifier(identifier)
+	if !ok {
+		return nil, errInvalidIdentifier
+	}
+
+	if capabilities, ok := c.CachedCapabilities(nodeID); ok {
+		return capabilities, nil
+	}
+
+	capabilities, err := c.hello(ctx, identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	c.capabilityCache.SetDefault(nodeID, capabilities)
+	return capabilities, nil
+}
+
+// CachedCapabilities returns the capabilities of the node ID if they have been
+// retrieved recently, without contacting the peer.
+func (c *Client) CachedCapabilities(nodeID string) (*Capabilities, bool) {
+	cached, found := c.capabilityCache.Get(nodeID)
+	if !found {
+		return nil, false
+	}
+	capabilities, ok := cached.(*Capabilities)
+	return capabilities, ok
+}
+
+func (c *Client) hello(ctx context.Context, identifier string) (
+	*Capabilities, error) {
+
+	resBytes, err := c.Request(ctx, identifier, EventHello, &HelloRequest{
+		Version: ProtocolVersion,
+	})
+	return helloCapabilities(resBytes, err)
+}
+
+// helloCapabilities interprets the reply to the HELLO event. Peers before the
+// HELLO event are reported with the legacy capabilities of their version.
+func helloCapabilities(resBytes []byte, err error) (*Capabilities, error) {
+	if errors.Is(err, ErrUnknownEvent) {
+		return &Capabilities{
+			Version: envelopeVersion,
+			Events:  legacyCapabilities[envelopeVersion],
+		}, nil
+	}
+	if errors.Is(err, errInvalidStatus) {
+		return &Capabilities{
+			Version: 0,
+			Events:  legacyCapabilities[0],
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var res HelloResponse
+	if err := proto.Unmarshal(resBytes, &res); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	return &Capabilities{
+		Version: res.GetVersion(),
+		Events:  res.GetCapabilities(),
+	}, nil
+}
+
+// Capabilities returns the events that the server can handle.
+func (s *Server) Capabilities() []string {
+	events := make([]string, 0, len(s.handlerMap))
+	for event := range s.handlerMap {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	return events
+}
+
+// hello handles the HELLO event by advertising the protocol version and the
+// capabilities of the server. It is registered on every server.
+func (s *Server) hello(_ context.Context, nodeID string, body []byte) (
+	protoreflect.ProtoMessage, error) {
+
+	var req HelloRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		return nil, BadRequest(
+			fmt.Errorf("unmarshal req body: %w", err))
+	}
+
+	log.Debug().Msgf("hello from %s (version %d)", nodeID,
+		req.GetVersion())
+
+	return &HelloResponse{
+		Version:      ProtocolVersion,
+		Capabilities: s.Capabilities(),
+	}, nil
+}
@@ -0,0 +1,110 @@
+package p2p
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHelloCapabilities(t *testing.T) {
+	hello := mustMarshal(t, &HelloResponse{
+		Version:      ProtocolVersion,
+		Capabilities: []string{EventHello, EventPing},
+	})
+	errTimeout := errors.New("timeout")
+
+	tests := []struct {
+		name     string
+		resBytes []byte
+		err      error
+		want     *Capabilities
+		wantErr  error
+	}{
+		{
+			name:     "current peer",
+			resBytes: hello,
+			want: &Capabilities{
+				Version: ProtocolVersion,
+				Events:  []string{EventHello, EventPing},
+			},
+		},
+		{
+			name: "envelope peer without hello",
+			err:  ErrUnknownEvent,
+			want: &Capabilities{
+				Version: 1,
+				Events:  legacyCapabilities[1],
+			},
+		},
+		{
+			name: "pre-envelope peer",
+			err:  errInvalidStatus,
+			want: &Capabilities{
+				Version: 0,
+				Events:  legacyCapabilities[0],
+			},
+		},
+		{
+			name:    "unreachable peer",
+			err:     errTimeout,
+			wantErr: errTimeout,
+		},
+		{
+			name:    "rejected",
+			err:     ErrNotFriend,
+			wantErr: ErrNotFriend,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := helloCapabilities(tt.resBytes, tt.err)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLegacyCapabilities(t *testing.T) {
+	v0 := &Capabilities{Version: 0, Events: legacyCapabilities[0]}
+	for _, event := range []string{EventPing, EventFriendInvite} {
+		if !v0.Supports(event) {
+			t.Errorf("version 0 does not support %s", event)
+		}
+	}
+	for _, event := range []string{EventHello, EventFriendRemove,
+		EventLetterSend} {
+
+		if v0.Supports(event) {
+			t.Errorf("version 0 supports %s", event)
+		}
+	}
+}
+
+func TestIsTemporary(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"network", errors.New("connection refused"), true},
+		{"rate limited", ErrRateLimited, true},
+		{"unavailable", ErrUnavailable, true},
+		{"empty reply", errInvalidStatus, false},
+		{"malformed reply", errMalformedResponse, false},
+		{"too large", errMessageTooLarge, false},
+		{"unknown event", ErrUnknownEvent, false},
+		{"not friend", ErrNotFriend, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTemporary(tt.err); got != tt.want {
+				t.Fatalf("IsTemporary(%v) = %v, want %v",
+					tt.err, got, tt.want)
+			}
+		})
+	}
+}
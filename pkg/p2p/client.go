@@ -1,12 +1,16 @@
 package p2p
 
 import (
+	"context"
 	"crypto/tls"
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
+	"net"
+	"sync"
+	"time"
 
+	"github.com/patrickmn/go-cache"
+	"github.com/rs/zerolog/log"
 	"google.golang.org/protobuf/proto"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 )
@@ -26,50 +30,231 @@ var (
 	errInvalidIdentifier = errors.New("invalid identifier")
 )
 
+// idempotentEvents are the events whose requests have no effect on the peer
+// other than the response, so that they can be sent again if the connection
+// fails after they are sent.
+var idempotentEvents = map[string]bool{
+	EventHello: true,
+	EventPing:  true,
+}
+
+// PeerStatusFunc is called when a peer is found to be reachable or unreachable
+// by a request to or from the peer.
+type PeerStatusFunc func(nodeID string, online bool)
+
 // Client is an P2P client to communicate with peers. Communication is performed
 // over TCP with TLS layer to ensure confidentiality and integrity. The
 // application layer is customized to allow verification of peers without the
 // need of certificate authorities.
+//
+// Connections to peers that support multiplexing are kept open and reused by
+// subsequent requests to the same node ID.
 type Client struct {
 	// cert is a client TLS certificate used for authenticating server
 	// certificate request.
 	cert tls.Certificate
+
+	config Config
+
+	// capabilityCache stores the capabilities of peers by node ID.
+	capabilityCache *cache.Cache
+
+	// connMu guards conns.
+	connMu sync.Mutex
+
+	// conns is a pool of multiplexed connections by node ID.
+	conns map[string]*muxConn
+
+	// peerStatus is called after each request with the reachability of
+	// the peer.
+	peerStatus PeerStatusFunc
 }
 
 // NewClient is a constructor function for Client.
-func NewClient(cert tls.Certificate) *Client {
+func NewClient(cert tls.Certificate, config Config) *Client {
 	return &Client{
-		cert: cert,
+		cert:            cert,
+		config:          config,
+		capabilityCache: cache.New(capabilitiesTTL, time.Minute*10),
+		conns:           map[string]*muxConn{},
 	}
 }
 
+// OnPeerStatus registers a function that is called after each request with
+// whether the peer has responded. Requests abandoned by the caller are not
+// reported. It must be called before sending any request.
+func (c *Client) OnPeerStatus(peerStatus PeerStatusFunc) {
+	c.peerStatus = peerStatus
+}
+
 // Request sends a P2P request to the specified identifier. After dialing to
 // a peer, it checks if the server node ID is the same as stated in the
 // identifier and rejects connection with invalid certificate. After
 // authentication succeeds, it constructs and sends a message to the server
 // in the protocol format [headerLength(2)||header(headerLength)||body(*)].
-func (c *Client) Request(identifier string, event string,
+// The server replies with a Response envelope. If the envelope carries an
+// error, it is returned as a ResponseError.
+//
+// If the peer negotiates multiplexing during the TLS handshake, the message
+// is sent in a frame on a pooled connection. Otherwise, the connection is
+// used for this request only.
+//
+// The request is abandoned when the context is done. If the context has no
+// deadline, the client waits for the response up to the read timeout.
+func (c *Client) Request(ctx context.Context, identifier string, event string,
 	body protoreflect.ProtoMessage) ([]byte, error) {
 
-	expectedNodeID, hostname, ok := ExtractIdentifier(identifier)
+	bodyBytes, err := proto.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal body proto: %w", err)
+	}
+
+	return c.RequestRaw(ctx, identifier, event, bodyBytes)
+}
+
+// RequestRaw is similar to Request but it receives a request body that has
+// already been encoded.
+func (c *Client) RequestRaw(ctx context.Context, identifier string,
+	event string, bodyBytes []byte) ([]byte, error) {
+
+	nodeID, hostname, ok := ExtractIdentifier(identifier)
 	if !ok {
 		return nil, errInvalidIdentifier
 	}
 
+	message, err := encodeMessage(event, bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) > c.config.MaxMessageSize {
+		return nil, errMessageTooLarge
+	}
+
+	requestCtx := ctx
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		requestCtx, cancel = context.WithTimeout(ctx,
+			c.config.readTimeout())
+		defer cancel()
+	}
+
+	responseBytes, err := c.roundTrip(requestCtx, nodeID, hostname, event,
+		message)
+	if errors.Is(err, errInvalidStatus) && event != EventHello {
+		err = c.legacyEmptyReply(requestCtx, identifier, event, err)
+	}
+	if c.peerStatus != nil && ctx.Err() == nil {
+		// Any response, including an error response, means that the
+		// peer is reachable.
+		var respErr *ResponseError
+		c.peerStatus(nodeID, err == nil || errors.As(err, &respErr))
+	}
+	return responseBytes, err
+}
+
+// legacyEmptyReply interprets an empty reply to the event. Peers before
+// envelopeVersion write nothing both for an unknown event and for a response
+// with default values. It returns nil if the peer is such a peer and supports
+// the event, ErrUnknownEvent if it does not support the event, and err
+// otherwise.
+func (c *Client) legacyEmptyReply(ctx context.Context, identifier string,
+	event string, err error) error {
+
+	capabilities, capErr := c.Capabilities(ctx, identifier)
+	if capErr != nil || capabilities.Version >= envelopeVersion {
+		return err
+	}
+	if !capabilities.Supports(event) {
+		return ErrUnknownEvent
+	}
+	return nil
+}
+
+// roundTrip sends the message of the event to the peer and returns the
+// response body. The pooled connection to the peer is used if there is one.
+func (c *Client) roundTrip(ctx context.Context, nodeID string,
+	hostname string, event string, message []byte) ([]byte, error) {
+
+	if mc := c.pooledConn(nodeID, hostname); mc != nil {
+		responseBytes, err := mc.roundTrip(ctx, message)
+		if err == nil {
+			return decodeResponse(responseBytes)
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+
+		// The pooled connection may have been closed by the peer. The
+		// request is retried on a new connection only if the peer
+		// cannot have handled it already.
+		if !errors.Is(err, errNotSent) && !idempotentEvents[event] {
+			return nil, err
+		}
+		log.Debug().Err(err).Msgf("retrying request to %s", nodeID)
+	}
+
+	conn, err := c.dial(ctx, nodeID, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	if conn.ConnectionState().NegotiatedProtocol != alpnMux {
+		defer conn.Close()
+		return c.requestOnce(ctx, conn, message)
+	}
+
+	mc := c.addConn(nodeID, newMuxConn(conn, hostname, c.config))
+	responseBytes, err := mc.roundTrip(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	return decodeResponse(responseBytes)
+}
+
+// Close closes all pooled connections.
+func (c *Client) Close() {
+	c.connMu.Lock()
+	conns := c.conns
+	c.conns = map[string]*muxConn{}
+	c.connMu.Unlock()
+
+	for _, mc := range conns {
+		mc.close(errConnClosed)
+	}
+}
+
+// dial connects to the peer at the hostname within the dial and handshake
+// timeouts and verifies that the node ID derived from the server certificate
+// is the expected one.
+func (c *Client) dial(ctx context.Context, expectedNodeID string,
+	hostname string) (*tls.Conn, error) {
+
 	tlsConfig := &tls.Config{
 		MinVersion:         tls.VersionTLS13,
 		Certificates:       []tls.Certificate{c.cert},
 		ClientAuth:         tls.RequestClientCert,
 		InsecureSkipVerify: true,
+		NextProtos:         []string{alpnMux},
 	}
 
-	conn, err := tls.Dial("tcp", hostname, tlsConfig)
+	dialer := &net.Dialer{Timeout: c.config.dialTimeout()}
+	rawConn, err := dialer.DialContext(ctx, "tcp", hostname)
 	if err != nil {
-		return nil, fmt.Errorf("tls dial: %w", err)
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+
+	conn := tls.Client(rawConn, tlsConfig)
+
+	handshakeCtx, cancel := context.WithTimeout(ctx,
+		c.config.handshakeTimeout())
+	defer cancel()
+	if err := conn.HandshakeContext(handshakeCtx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("tls handshake: %w", err)
 	}
-	defer conn.Close()
 
 	if len(conn.ConnectionState().PeerCertificates) == 0 {
+		conn.Close()
 		return nil, errNoServerCert
 	}
 
@@ -77,43 +262,104 @@ func (c *Client) Request(identifier string, event string,
 		conn.ConnectionState().PeerCertificates[0].PublicKey,
 	)
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
 
 	if expectedNodeID != actualNodeID {
+		conn.Close()
 		return nil, errUnexpectedServerNodeID
 	}
 
-	header := Header{Event: event}
-	headerBytes, err := proto.Marshal(&header)
-	if err != nil {
-		return nil, fmt.Errorf("marshal header proto: %w", err)
-	}
+	return conn, nil
+}
 
-	headerLength := make([]byte, 2)
-	binary.BigEndian.PutUint16(headerLength, uint16(len(headerBytes)))
+// pooledConn returns an open pooled connection to the node ID that is dialed
+// to the same hostname, or nil if there is none.
+func (c *Client) pooledConn(nodeID string, hostname string) *muxConn {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
 
-	bodyBytes, err := proto.Marshal(body)
-	if err != nil {
-		return nil, fmt.Errorf("marshal body proto: %w", err)
+	mc, ok := c.conns[nodeID]
+	if !ok {
+		return nil
+	}
+	if mc.closed() || mc.hostname != hostname {
+		delete(c.conns, nodeID)
+		return nil
 	}
+	return mc
+}
+
+// addConn adds a new connection to the pool. If another open connection to
+// the node ID has been added concurrently, that connection is kept in the
+// pool and the new connection serves the current request only until it is
+// closed by the idle timer.
+func (c *Client) addConn(nodeID string, mc *muxConn) *muxConn {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
 
-	if _, err := conn.Write(headerLength); err != nil {
-		return nil, fmt.Errorf("write header length: %w", err)
+	existing, ok := c.conns[nodeID]
+	if ok && !existing.closed() && existing.hostname == mc.hostname {
+		return mc
 	}
-	if _, err := conn.Write(headerBytes); err != nil {
-		return nil, fmt.Errorf("write header: %w", err)
+	if ok {
+		existing.close(errConnClosed)
 	}
-	if _, err := conn.Write(bodyBytes); err != nil {
-		return nil, fmt.Errorf("write body: %w", err)
+
+	c.conns[nodeID] = mc
+	return mc
+}
+
+// requestOnce sends a message on a connection to a peer that does not support
+// multiplexing. The end of the request is signaled by closing the write side
+// of the connection and the response is read until EOF or until the context
+// is done.
+func (c *Client) requestOnce(ctx context.Context, conn *tls.Conn,
+	message []byte) ([]byte, error) {
+
+	stop := interruptOnDone(ctx, conn)
+	defer stop()
+
+	err := conn.SetWriteDeadline(time.Now().Add(c.config.writeTimeout()))
+	if err != nil {
+		return nil, fmt.Errorf("set write deadline: %w", err)
+	}
+	if _, err := conn.Write(message); err != nil {
+		return nil, contextError(ctx, fmt.Errorf("write message: %w",
+			err))
 	}
 	if err := conn.CloseWrite(); err != nil {
-		return nil, fmt.Errorf("close write: %w", err)
+		return nil, contextError(ctx, fmt.Errorf("close write: %w",
+			err))
 	}
 
-	response, err := io.ReadAll(conn)
+	responseBytes, err := readMessage(conn, c.config.MaxMessageSize)
 	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
+		return nil, contextError(ctx, err)
+	}
+	return decodeResponse(responseBytes)
+}
+
+// interruptOnDone interrupts blocking reads and writes on the connection when
+// the context is done. The returned function stops watching the context.
+func interruptOnDone(ctx context.Context, conn net.Conn) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// contextError returns the context error instead of err if the context is
+// done, since err is then caused by interrupting the connection.
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("request interrupted: %w", ctx.Err())
 	}
-	return response, nil
+	return err
 }
@@ -0,0 +1,68 @@
+package p2p
+
+import "time"
+
+// Config defines the configuration options for the P2P client and server.
+// Timeouts are in seconds.
+type Config struct {
+	// RateLimit is the maximum number of requests per minute that the
+	// server accepts from each peer. Zero disables rate limiting.
+	RateLimit int
+
+	// DialTimeout is the maximum duration for establishing a TCP
+	// connection to a peer.
+	DialTimeout int
+
+	// HandshakeTimeout is the maximum duration for the TLS handshake on
+	// both sides.
+	HandshakeTimeout int
+
+	// ReadTimeout is the maximum duration for reading a message. The
+	// client also uses it as the maximum duration for waiting for the
+	// response when the request context has no deadline.
+	ReadTimeout int
+
+	// WriteTimeout is the maximum duration for writing a message.
+	WriteTimeout int
+
+	// IdleTimeout is the duration after which an idle multiplexed
+	// connection is closed.
+	IdleTimeout int
+
+	// MaxMessageSize is the maximum size in bytes of a request or
+	// response message.
+	MaxMessageSize int
+}
+
+// DefaultConfig returns all default values for the Config struct.
+func DefaultConfig() Config {
+	return Config{
+		RateLimit:        120,
+		DialTimeout:      10,
+		HandshakeTimeout: 10,
+		ReadTimeout:      30,
+		WriteTimeout:     30,
+		IdleTimeout:      120,
+		MaxMessageSize:   4 << 20,
+	}
+}
+
+func (c Config) dialTimeout() time.Duration {
+	return time.Duration(c.DialTimeout) * time.Second
+}
+
+func (c Config) handshakeTimeout() time.Duration {
+	return time.Duration(c.HandshakeTimeout) * time.Second
+}
+
+func (c Config) readTimeout() time.Duration {
+	return time.Duration(c.ReadTimeout) * time.Second
+}
+
+func (c Config) writeTimeout() time.Duration {
+	return time.Duration(c.WriteTimeout) * time.Second
+}
+
+func (c Config) idleTimeout() time.Duration {
+	return time.Duration(c.IdleTimeout) * time.Second
+}
@@ -0,0 +1,134 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error codes carried in the response envelope when the request fails.
+const (
+	CodeBadRequest   = "BAD_REQUEST"
+	CodeNotFriend    = "NOT_FRIEND"
+	CodeBlocked      = "BLOCKED"
+	CodeUnknownEvent = "UNKNOWN_EVENT"
+	CodeRateLimited  = "RATE_LIMITED"
+	CodeUnavailable  = "UNAVAILABLE"
+	CodeInternal     = "INTERNAL"
+)
+
+var (
+	// ErrBadRequest is returned by the peer when the request cannot be
+	// decoded.
+	ErrBadRequest = &ResponseError{
+		Code:    CodeBadRequest,
+		Message: "bad request",
+	}
+
+	// ErrNotFriend is returned by the peer when the request requires
+	// friendship but the requester is not a friend of the peer.
+	ErrNotFriend = &ResponseError{
+		Code:    CodeNotFriend,
+		Message: "not a friend",
+	}
+
+	// ErrBlocked is returned by the peer when the requester is blocked.
+	ErrBlocked = &ResponseError{
+		Code:    CodeBlocked,
+		Message: "blocked",
+	}
+
+	// ErrUnknownEvent is returned by the peer when it has no handler for
+	// the requested event.
+	ErrUnknownEvent = &ResponseError{
+		Code:    CodeUnknownEvent,
+		Message: "unknown event",
+	}
+
+	// ErrRateLimited is returned by the peer when the requester sends too
+	// many requests in a short period of time.
+	ErrRateLimited = &ResponseError{
+		Code:    CodeRateLimited,
+		Message: "rate limited",
+	}
+
+	// ErrUnavailable is returned by the peer when it is shutting down and
+	// does not handle the request.
+	ErrUnavailable = &ResponseError{
+		Code:    CodeUnavailable,
+		Message: "unavailable",
+	}
+
+	// ErrInternal is returned by the peer when it fails to process the
+	// request because of an unexpected error.
+	ErrInternal = &ResponseError{
+		Code:    CodeInternal,
+		Message: "internal error",
+	}
+)
+
+// ResponseError is an error carried in the response envelope. On the server
+// side, handlers return it to control the error sent to the client. On the
+// client side, it is returned when the peer responds with an error.
+// ResponseErrors with the same code are considered equal by errors.Is.
+type ResponseError struct {
+	Code    string
+	Message string
+
+	// cause is the underlying error that is logged by the server but not
+	// sent to the client.
+	cause error
+}
+
+// Error implements the error interface.
+func (e *ResponseError) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s", e.Message, e.cause)
+	}
+	return e.Message
+}
+
+// Is reports whether the target is a ResponseError with the same code.
+func (e *ResponseError) Is(target error) bool {
+	var t *ResponseError
+	if !errors.As(target, &t) {
+		return false
+	}
+	return e.Code == t.Code
+}
+
+// Unwrap returns the underlying cause of the error.
+func (e *ResponseError) Unwrap() error {
+	return e.cause
+}
+
+// BadRequest wraps the cause of a malformed request into a ResponseError with
+// the bad request code.
+func BadRequest(cause error) error {
+	return &ResponseError{
+		Code:    CodeBadRequest,
+		Message: ErrBadRequest.Message,
+		cause:   cause,
+	}
+}
+
+// IsTemporary reports whether the request failed because the peer could not
+// be reached or asked to retry later, so that it is worth retrying the same
+// request. Errors responded by the peer are not temporary except for rate
+// limiting and shutting down. Protocol errors, such as a response that cannot
+// be decoded, are not temporary either, since the peer may have processed the
+// request.
+func IsTemporary(err error) bool {
+	if errors.Is(err, errInvalidStatus) ||
+		errors.Is(err, errMalformedResponse) ||
+		errors.Is(err, errMessageTooLarge) {
+
+		return false
+	}
+
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) {
+		return true
+	}
+	return respErr.Code == CodeRateLimited ||
+		respErr.Code == CodeUnavailable
+}
@@ -0,0 +1,151 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// alpnMux is the ALPN protocol name negotiated during the TLS handshake when
+// both sides support multiplexed connections. Peers that do not negotiate it
+// fall back to one request per connection.
+const alpnMux = "lettered-mux"
+
+// errMessageTooLarge is an error indicating that a message exceeds the
+// maximum message size.
+var errMessageTooLarge = errors.New("message too large")
+
+var (
+	// errInvalidStatus is an error indicating that a response has no
+	// status because it is empty. Peers before envelopeVersion close the
+	// connection without a response when the request fails.
+	errInvalidStatus = errors.New("invalid response status")
+
+	// errMalformedResponse is an error indicating that a response cannot
+	// be decoded.
+	errMalformedResponse = errors.New("malformed response")
+)
+
+// writeFrame writes a frame in the format
+// [requestID(4)||payloadLength(4)||payload(payloadLength)] to the writer.
+// The request ID pairs a response with its request on a multiplexed
+// connection.
+func writeFrame(w io.Writer, requestID uint32, payload []byte,
+	maxSize int) error {
+
+	if len(payload) > maxSize {
+		return errMessageTooLarge
+	}
+
+	frame := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint32(frame[:4], requestID)
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(payload)))
+	copy(frame[8:], payload)
+
+	if _, err := w.Write(frame); err != nil {
+		return fmt.Errorf("write frame: %w", err)
+	}
+	return nil
+}
+
+// readFrame reads a frame written by writeFrame from the reader. Frames with
+// payload larger than maxSize are rejected before the payload is read.
+func readFrame(r io.Reader, maxSize int) (uint32, []byte, error) {
+	prefix := make([]byte, 8)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return 0, nil, fmt.Errorf("read frame prefix: %w", err)
+	}
+
+	requestID := binary.BigEndian.Uint32(prefix[:4])
+	payloadLength := binary.BigEndian.Uint32(prefix[4:8])
+	if uint64(payloadLength) > uint64(maxSize) {
+		return 0, nil, errMessageTooLarge
+	}
+
+	payload := make([]byte, payloadLength)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, fmt.Errorf("read frame payload: %w", err)
+	}
+	return requestID, payload, nil
+}
+
+// readMessage reads a message until EOF. Messages larger than maxSize are
+// rejected.
+func readMessage(r io.Reader, maxSize int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("read message: %w", err)
+	}
+	if len(data) > maxSize {
+		return nil, errMessageTooLarge
+	}
+	return data, nil
+}
+
+// encodeMessage encodes a request in the protocol format
+// [headerLength(2)||header(headerLength)||body(*)].
+func encodeMessage(event string, bodyBytes []byte) ([]byte, error) {
+	header := Header{
+		Event:   event,
+		Version: ProtocolVersion,
+	}
+	headerBytes, err := proto.Marshal(&header)
+	if err != nil {
+		return nil, fmt.Errorf("marshal header proto: %w", err)
+	}
+
+	message := make([]byte, 2+len(headerBytes)+len(bodyBytes))
+	binary.BigEndian.PutUint16(message[:2], uint16(len(headerBytes)))
+	copy(message[2:], headerBytes)
+	copy(message[2+len(headerBytes):], bodyBytes)
+	return message, nil
+}
+
+// encodeResponse encodes the response for a client of the protocol version.
+// Clients before envelopeVersion expect the body of a successful response
+// without the envelope, and nothing if the request fails.
+func encodeResponse(response *Response, version uint32) ([]byte, error) {
+	if version < envelopeVersion {
+		if response.GetStatus() != Status_STATUS_OK {
+			return nil, nil
+		}
+		return response.GetBody(), nil
+	}
+
+	responseBytes, err := proto.Marshal(response)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	return responseBytes, nil
+}
+
+// decodeResponse decodes the Response envelope and returns its body. If the
+// envelope carries an error, it is returned as a ResponseError. A non-empty
+// reply without a valid status is the body written by a peer before
+// envelopeVersion and is returned as is. Such peers never set field 1 to a
+// valid status. An empty reply is a protocol error.
+func decodeResponse(responseBytes []byte) ([]byte, error) {
+	if len(responseBytes) == 0 {
+		return nil, errInvalidStatus
+	}
+
+	var response Response
+	if err := proto.Unmarshal(responseBytes, &response); err != nil {
+		return nil, fmt.Errorf("%w: %s", errMalformedResponse,
+			err.Error())
+	}
+	switch response.GetStatus() {
+	case Status_STATUS_OK:
+		return response.GetBody(), nil
+	case Status_STATUS_ERROR:
+		return nil, &ResponseError{
+			Code:    response.GetErrorCode(),
+			Message: response.GetMessage(),
+		}
+	default:
+		return responseBytes, nil
+	}
+}
@@ -0,0 +1,114 @@
+package p2p
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeResponse(t *testing.T) {
+	ping := mustMarshal(t, &PingResponse{Message: "pong"})
+	accepted := mustMarshal(t, &FriendInviteResponse{
+		Accepted: true,
+		Alias:    "alice",
+	})
+	rejected := mustMarshal(t, &FriendInviteResponse{Alias: "alice"})
+	ok := mustMarshal(t, &Response{
+		Status: Status_STATUS_OK,
+		Body:   []byte("body"),
+	})
+	okEmpty := mustMarshal(t, &Response{Status: Status_STATUS_OK})
+	failed := mustMarshal(t, &Response{
+		Status:    Status_STATUS_ERROR,
+		ErrorCode: CodeNotFriend,
+		Message:   "not a friend",
+	})
+
+	tests := []struct {
+		name     string
+		payload  []byte
+		wantBody string
+		wantErr  error
+	}{
+		{"empty", nil, "", errInvalidStatus},
+		{"malformed", []byte{0xff}, "", errMalformedResponse},
+		{"legacy ping", ping, string(ping), nil},
+		{"legacy accepted invite", accepted, string(accepted), nil},
+		{"legacy rejected invite", rejected, string(rejected), nil},
+		{"envelope ok", ok, "body", nil},
+		{"envelope ok empty body", okEmpty, "", nil},
+		{"envelope error", failed, "", ErrNotFriend},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := decodeResponse(tt.payload)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if string(body) != tt.wantBody {
+				t.Fatalf("body = %q, want %q", body,
+					tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEncodeResponse(t *testing.T) {
+	accepted := mustMarshal(t, &FriendInviteResponse{
+		Accepted: true,
+		Alias:    "alice",
+	})
+	ok := &Response{Status: Status_STATUS_OK, Body: accepted}
+	okEmpty := &Response{Status: Status_STATUS_OK}
+	failed := errorResponse(ErrNotFriend)
+
+	tests := []struct {
+		name     string
+		response *Response
+		version  uint32
+		wantBody string
+		wantErr  error
+	}{
+		{"v0 ok", ok, 0, string(accepted), nil},
+		{"v0 ok empty body", okEmpty, 0, "", errInvalidStatus},
+		{"v0 error", failed, 0, "", errInvalidStatus},
+		{"v1 ok", ok, 1, string(accepted), nil},
+		{"v1 ok empty body", okEmpty, 1, "", nil},
+		{"v1 error", failed, 1, "", ErrNotFriend},
+		{"v2 ok", ok, 2, string(accepted), nil},
+		{"v2 ok empty body", okEmpty, 2, "", nil},
+		{"v2 error", failed, 2, "", ErrNotFriend},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := encodeResponse(tt.response, tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.version < envelopeVersion &&
+				string(encoded) != tt.wantBody {
+				t.Fatalf("encoded = %q, want raw body %q",
+					encoded, tt.wantBody)
+			}
+
+			body, err := decodeResponse(encoded)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if string(body) != tt.wantBody {
+				t.Fatalf("body = %q, want %q", body,
+					tt.wantBody)
+			}
+		})
+	}
+}
@@ -8,10 +8,24 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// MaxAliasLength is the maximum number of characters of an alias.
+const MaxAliasLength = 64
+
 var (
+	// ErrInvalidAlias is an error indicating that the alias is blank or too
+	// long to be accepted by peers.
+	ErrInvalidAlias = errors.New("invalid alias")
+
+	// ErrInvalidHostname is an error indicating that the hostname is not an
+	// address (host:port) at which peers can reach a node.
+	ErrInvalidHostname = errors.New("invalid hostname")
+
 	// errUnsupportedPrivateKey is an error indicating that the private key
 	// is in an unsupported type.
 	errUnsupportedPrivateKey = errors.New("unsupported private key")
@@ -55,3 +69,41 @@ func ExtractIdentifier(identifier string) (string, string, bool) {
 	}
 	return tokens[0], tokens[1], true
 }
+
+// ValidateHostname checks that the hostname is an address (host:port) at which
+// peers can reach a node. The returned error wraps ErrInvalidHostname and
+// describes how to fix the hostname.
+func ValidateHostname(hostname string) error {
+	if hostname == "" {
+		return fmt.Errorf("%w: must be set to the address (host:port) "+
+			"at which peers reach the node, e.g. example.com:1926",
+			ErrInvalidHostname)
+	}
+
+	host, port, err := net.SplitHostPort(hostname)
+	if err != nil || host == "" {
+		return fmt.Errorf("%w: must be host:port, e.g. "+
+			"example.com:1926, got %q", ErrInvalidHostname,
+			hostname)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%w: must have a port between 1 and 65535, "+
+			"got %q", ErrInvalidHostname, port)
+	}
+	return nil
+}
+
+// ValidateAlias checks that the alias is neither blank nor longer than
+// MaxAliasLength characters, so that peers accept it. The returned error wraps
+// ErrInvalidAlias.
+func ValidateAlias(alias string) error {
+	if strings.TrimSpace(alias) == "" {
+		return fmt.Errorf("%w: must not be empty", ErrInvalidAlias)
+	}
+	if utf8.RuneCountInString(alias) > MaxAliasLength {
+		return fmt.Errorf("%w: must be at most %d characters, got %d",
+			ErrInvalidAlias, MaxAliasLength,
+			utf8.RuneCountInString(alias))
+	}
+	return nil
+}
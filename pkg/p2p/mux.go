@@ -0,0 +1,185 @@
+package p2p
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// errConnClosed is an error indicating that the multiplexed connection
+	// has been closed because it is idle or the client is closed.
+	errConnClosed = errors.New("connection closed")
+
+	// errNotSent is an error indicating that a request has not been sent
+	// because the multiplexed connection had been closed before.
+	errNotSent = errors.New("request not sent")
+)
+
+// muxConn is a long-lived connection to a peer on which multiple requests can
+// be in flight at the same time. Each request is sent in a frame tagged with
+// a request ID, and the response frame with the same ID is returned to the
+// requester.
+type muxConn struct {
+	conn *tls.Conn
+
+	// hostname is the peer hostname that the connection is dialed to.
+	hostname string
+
+	config Config
+
+	// writeMu serializes frames written to the connection.
+	writeMu sync.Mutex
+
+	// mu guards the fields below.
+	mu        sync.Mutex
+	nextID    uint32
+	pending   map[uint32]chan muxResult
+	closeErr  error
+	idleTimer *time.Timer
+}
+
+type muxResult struct {
+	payload []byte
+	err     error
+}
+
+func newMuxConn(conn *tls.Conn, hostname string, config Config) *muxConn {
+	mc := &muxConn{
+		conn:     conn,
+		hostname: hostname,
+		config:   config,
+		pending:  map[uint32]chan muxResult{},
+	}
+	mc.idleTimer = time.AfterFunc(config.idleTimeout(), mc.closeIfIdle)
+
+	go mc.readLoop()
+	return mc
+}
+
+// roundTrip sends a request message and waits for its response payload until
+// the context is done. A request abandoned by its context does not affect
+// other requests on the connection. The returned error wraps errNotSent if the
+// connection is closed before the request is written.
+func (mc *muxConn) roundTrip(ctx context.Context, message []byte) ([]byte,
+	error) {
+
+	mc.mu.Lock()
+	if mc.closeErr != nil {
+		closeErr := mc.closeErr
+		mc.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", errNotSent, closeErr.Error())
+	}
+	mc.nextID++
+	requestID := mc.nextID
+	resultCh := make(chan muxResult, 1)
+	mc.pending[requestID] = resultCh
+	mc.idleTimer.Stop()
+	mc.mu.Unlock()
+
+	if err := mc.write(requestID, message); err != nil {
+		// A partially written frame corrupts the stream, so the
+		// connection cannot be used anymore.
+		mc.close(err)
+	}
+
+	select {
+	case result := <-resultCh:
+		mc.resetIdleTimer()
+		return result.payload, result.err
+	case <-ctx.Done():
+		mc.mu.Lock()
+		delete(mc.pending, requestID)
+		mc.mu.Unlock()
+
+		mc.resetIdleTimer()
+		return nil, fmt.Errorf("wait response: %w", ctx.Err())
+	}
+}
+
+// write writes a request frame within the write timeout.
+func (mc *muxConn) write(requestID uint32, message []byte) error {
+	mc.writeMu.Lock()
+	defer mc.writeMu.Unlock()
+
+	err := mc.conn.SetWriteDeadline(
+		time.Now().Add(mc.config.writeTimeout()))
+	if err != nil {
+		return fmt.Errorf("set write deadline: %w", err)
+	}
+	return writeFrame(mc.conn, requestID, message,
+		mc.config.MaxMessageSize)
+}
+
+// resetIdleTimer restarts the idle timer if there is no pending request.
+func (mc *muxConn) resetIdleTimer() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.closeErr == nil && len(mc.pending) == 0 {
+		mc.idleTimer.Reset(mc.config.idleTimeout())
+	}
+}
+
+// readLoop reads response frames and delivers them to the pending requests
+// until the connection is closed.
+func (mc *muxConn) readLoop() {
+	for {
+		requestID, payload, err := readFrame(mc.conn,
+			mc.config.MaxMessageSize)
+		if err != nil {
+			mc.close(err)
+			return
+		}
+
+		mc.mu.Lock()
+		resultCh, ok := mc.pending[requestID]
+		delete(mc.pending, requestID)
+		mc.mu.Unlock()
+
+		if ok {
+			resultCh <- muxResult{payload: payload}
+		}
+	}
+}
+
+// closed reports whether the connection has been closed.
+func (mc *muxConn) closed() bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.closeErr != nil
+}
+
+func (mc *muxConn) closeIfIdle() {
+	mc.mu.Lock()
+	idle := len(mc.pending) == 0
+	mc.mu.Unlock()
+
+	if idle {
+		mc.close(errConnClosed)
+	}
+}
+
+// close closes the connection and fails all pending requests with the
+// specified error. Subsequent calls have no effect.
+func (mc *muxConn) close(err error) {
+	mc.mu.Lock()
+	if mc.closeErr != nil {
+		mc.mu.Unlock()
+		return
+	}
+	mc.closeErr = err
+	pending := mc.pending
+	mc.pending = map[uint32]chan muxResult{}
+	mc.idleTimer.Stop()
+	mc.mu.Unlock()
+
+	_ = mc.conn.Close()
+
+	for _, resultCh := range pending {
+		resultCh <- muxResult{err: err}
+	}
+}
@@ -1,14 +1,20 @@
 package p2p
 
 import (
+	"context"
 	"fmt"
 
 	"google.golang.org/protobuf/proto"
 )
 
 const (
-	EventPing         = "PING"
-	EventFriendInvite = "FRIEND_INVITE"
+	EventHello          = "HELLO"
+	EventPing           = "PING"
+	EventFriendInvite   = "FRIEND_INVITE"
+	EventFriendRemove   = "FRIEND_REMOVE"
+	EventLetterSend     = "LETTER_SEND"
+	EventProfileUpdate  = "PROFILE_UPDATE"
+	EventHostnameUpdate = "HOSTNAME_UPDATE"
 )
 
 // Peer is a wrapper of P2P client struct containing useful functionality for
@@ -29,9 +35,17 @@ func NewPeer(client *Client, identifier string) *Peer {
 	}
 }
 
+// Capabilities returns the protocol version and the events supported by the
+// peer. The result is cached by the client.
+func (p *Peer) Capabilities(ctx context.Context) (*Capabilities, error) {
+	return p.client.Capabilities(ctx, p.identifier)
+}
+
 // Ping invokes PING event request.
-func (p *Peer) Ping(req *PingRequest) (*PingResponse, error) {
-	resBytes, err := p.client.Request(p.identifier, EventPing, req)
+func (p *Peer) Ping(ctx context.Context, req *PingRequest) (*PingResponse,
+	error) {
+
+	resBytes, err := p.client.Request(ctx, p.identifier, EventPing, req)
 	if err != nil {
 		return nil, err
 	}
@@ -44,10 +58,11 @@ func (p *Peer) Ping(req *PingRequest) (*PingResponse, error) {
 }
 
 // FriendInvite invokes FRIEND_INVITE event request.
-func (p *Peer) FriendInvite(req *FriendInviteRequest) (*FriendInviteResponse,
-	error) {
+func (p *Peer) FriendInvite(ctx context.Context, req *FriendInviteRequest) (
+	*FriendInviteResponse, error) {
 
-	resBytes, err := p.client.Request(p.identifier, EventFriendInvite, req)
+	resBytes, err := p.client.Request(ctx, p.identifier, EventFriendInvite,
+		req)
 	if err != nil {
 		return nil, err
 	}
@@ -58,3 +73,71 @@ func (p *Peer) FriendInvite(req *FriendInviteRequest) (*FriendInviteResponse,
 	}
 	return &res, nil
 }
+
+// FriendRemove invokes FRIEND_REMOVE event request.
+func (p *Peer) FriendRemove(ctx context.Context, req *FriendRemoveRequest) (
+	*FriendRemoveResponse, error) {
+
+	resBytes, err := p.client.Request(ctx, p.identifier, EventFriendRemove,
+		req)
+	if err != nil {
+		return nil, err
+	}
+
+	var res FriendRemoveResponse
+	if err := proto.Unmarshal(resBytes, &res); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	return &res, nil
+}
+
+// LetterSend invokes LETTER_SEND event request.
+func (p *Peer) LetterSend(ctx context.Context, req *LetterSendRequest) (
+	*LetterSendResponse, error) {
+
+	resBytes, err := p.client.Request(ctx, p.identifier, EventLetterSend,
+		req)
+	if err != nil {
+		return nil, err
+	}
+
+	var res LetterSendResponse
+	if err := proto.Unmarshal(resBytes, &res); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	return &res, nil
+}
+
+// ProfileUpdate invokes PROFILE_UPDATE event request.
+func (p *Peer) ProfileUpdate(ctx context.Context, req *ProfileUpdateRequest) (
+	*ProfileUpdateResponse, error) {
+
+	resBytes, err := p.client.Request(ctx, p.identifier, EventProfileUpdate,
+		req)
+	if err != nil {
+		return nil, err
+	}
+
+	var res ProfileUpdateResponse
+	if err := proto.Unmarshal(resBytes, &res); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	return &res, nil
+}
+
+// HostnameUpdate invokes HOSTNAME_UPDATE event request.
+func (p *Peer) HostnameUpdate(ctx context.Context,
+	req *HostnameUpdateRequest) (*HostnameUpdateResponse, error) {
+
+	resBytes, err := p.client.Request(ctx, p.identifier,
+		EventHostnameUpdate, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var res HostnameUpdateResponse
+	if err := proto.Unmarshal(resBytes, &res); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	return &res, nil
+}
@@ -0,0 +1,53 @@
+package p2p
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter counts requests from each peer in fixed time windows and rejects
+// requests that exceed the limit in the current window.
+type rateLimiter struct {
+	// mu guards all fields.
+	mu          sync.Mutex
+	limit       int
+	window      time.Duration
+	windowStart time.Time
+	counts      map[string]int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		counts: map[string]int{},
+	}
+}
+
+// allow records a request from the node ID and reports whether it is within
+// the limit. A non-positive limit allows every request.
+func (l *rateLimiter) allow(nodeID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	if now.Sub(l.windowStart) >= l.window {
+		l.windowStart = now
+		l.counts = map[string]int{}
+	}
+
+	l.counts[nodeID]++
+	return l.counts[nodeID] <= l.limit
+}
+
+// setLimit changes the limit. It applies to the current window.
+func (l *rateLimiter) setLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = limit
+}
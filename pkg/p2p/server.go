@@ -1,54 +1,122 @@
 package p2p
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
-// errHeaderTooShort is an error indicating that the received message does not
-// have enough length to be able to process the header.
-var errHeaderTooShort = errors.New("header too short")
+// maxConcurrentRequests is the maximum number of requests handled at the same
+// time on a multiplexed connection.
+const maxConcurrentRequests = 16
 
-// HandlerFunc defines the handler used by P2P service.
-type HandlerFunc func(nodeID string, body []byte) (protoreflect.ProtoMessage,
-	error)
+var (
+	// ErrServerClosed is returned by Run after the server is shut down.
+	ErrServerClosed = errors.New("server closed")
+
+	// errHeaderTooShort is an error indicating that the received message
+	// does not have enough length to be able to process the header.
+	errHeaderTooShort = errors.New("header too short")
+)
+
+// HandlerFunc defines the handler used by P2P service. The context is canceled
+// when the connection of the request is closed. It carries the certificate of
+// the peer, which is returned by PeerCertificate.
+type HandlerFunc func(ctx context.Context, nodeID string, body []byte) (
+	protoreflect.ProtoMessage, error)
+
+// GuardFunc decides whether a connection from the peer with the specified node
+// ID and remote host is allowed. It returns false to reject the connection. If
+// it returns an error, the request is rejected as an internal error.
+type GuardFunc func(nodeID string, host string) (bool, error)
 
 // Server is a custom TLS over TCP server that handles P2P communication from
 // peer nodes.
 type Server struct {
-	cert       tls.Certificate
-	port       int
-	handlerMap map[string]HandlerFunc
+	cert        tls.Certificate
+	port        int
+	config      Config
+	handlerMap  map[string]HandlerFunc
+	guard       GuardFunc
+	peerStatus  PeerStatusFunc
+	rateLimiter *rateLimiter
+
+	// ctx is the parent context of all requests. It is canceled when the
+	// server is forced to close.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu guards the fields below.
+	mu           sync.Mutex
+	listener     net.Listener
+	conns        map[*tls.Conn]struct{}
+	shuttingDown bool
+
+	// connWg waits for all connections to be handled.
+	connWg sync.WaitGroup
 }
 
 // NewServer is the constructor function for Server.
-func NewServer(cert tls.Certificate, port int) *Server {
-	return &Server{
-		cert:       cert,
-		port:       port,
-		handlerMap: map[string]HandlerFunc{},
+func NewServer(cert tls.Certificate, port int, config Config) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		cert:        cert,
+		port:        port,
+		config:      config,
+		handlerMap:  map[string]HandlerFunc{},
+		rateLimiter: newRateLimiter(config.RateLimit, time.Minute),
+		ctx:         ctx,
+		cancel:      cancel,
+		conns:       map[*tls.Conn]struct{}{},
 	}
+	s.On(EventHello, s.hello)
+	return s
 }
 
+// On registers the handler of the specified event.
 func (s *Server) On(event string, handler HandlerFunc) {
 	s.handlerMap[event] = handler
 }
 
-// Run starts the server.
+// Guard registers a function that is checked on every connection after the
+// peer is authenticated and before any handler runs.
+func (s *Server) Guard(guard GuardFunc) {
+	s.guard = guard
+}
+
+// OnPeerStatus registers a function that is called with every authenticated
+// peer that connects to the server, since the peer is evidently online.
+func (s *Server) OnPeerStatus(peerStatus PeerStatusFunc) {
+	s.peerStatus = peerStatus
+}
+
+// SetRateLimit changes the maximum number of requests per minute that the
+// server accepts from each peer while the server is running. Zero disables
+// rate limiting.
+func (s *Server) SetRateLimit(limit int) {
+	s.rateLimiter.setLimit(limit)
+}
+
+// Run starts the server. It blocks until the server is shut down and then
+// returns ErrServerClosed.
 func (s *Server) Run() error {
 	tlsConfig := &tls.Config{
 		MinVersion:         tls.VersionTLS13,
 		Certificates:       []tls.Certificate{s.cert},
 		ClientAuth:         tls.RequestClientCert,
 		InsecureSkipVerify: true,
+		NextProtos:         []string{alpnMux},
 	}
 
 	listener, err := tls.Listen("tcp", ":"+strconv.Itoa(s.port), tlsConfig)
@@ -57,11 +125,22 @@ func (s *Server) Run() error {
 	}
 	defer listener.Close()
 
+	s.mu.Lock()
+	if s.shuttingDown {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
 	log.Info().Msgf("listening to p2p connection on port %d", s.port)
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if s.isShuttingDown() {
+				return ErrServerClosed
+			}
 			log.Error().Err(err).
 				Msg("error accepting connection")
 			continue
@@ -77,14 +156,92 @@ func (s *Server) Run() error {
 			continue
 		}
 
-		go s.handleConnection(tlsConn)
+		if !s.trackConn(tlsConn) {
+			tlsConn.Close()
+			return ErrServerClosed
+		}
+
+		go func() {
+			defer s.untrackConn(tlsConn)
+			s.handleConnection(tlsConn)
+		}()
+	}
+}
+
+// Shutdown gracefully shuts down the server. It stops accepting new
+// connections and new requests, and waits for in-flight requests to complete.
+// If the context is done before that, the remaining requests are canceled and
+// their connections are closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shuttingDown = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	// Interrupt connections waiting for requests. Requests that have been
+	// read keep running.
+	for conn := range s.conns {
+		_ = conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.connWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+
+		return fmt.Errorf("wait connections: %w", ctx.Err())
+	}
+}
+
+// trackConn registers a connection to be waited for on shutdown. It returns
+// false if the server is shutting down.
+func (s *Server) trackConn(conn *tls.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shuttingDown {
+		return false
 	}
+	s.conns[conn] = struct{}{}
+	s.connWg.Add(1)
+	return true
+}
+
+func (s *Server) untrackConn(conn *tls.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+
+	s.connWg.Done()
+}
+
+func (s *Server) isShuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shuttingDown
 }
 
 // handleConnection is a middleware, authenticating the connection and transform
 // request body for easier use in the handler functions. It rejects the client
 // without certificate and then extract the request body as in the designed
-// protocol format.
+// protocol format. If the client negotiates multiplexing, the connection is
+// served until the client closes it. Otherwise, a single request is read
+// until EOF.
 func (s *Server) handleConnection(conn *tls.Conn) {
 	defer func() {
 		if err := conn.Close(); err != nil {
@@ -95,11 +252,12 @@ func (s *Server) handleConnection(conn *tls.Conn) {
 	}()
 
 	// Handshake so that client certificate can be read from the server.
-	if !conn.ConnectionState().HandshakeComplete {
-		if err := conn.Handshake(); err != nil {
-			log.Error().Err(err).Msg("error handshaking")
-			return
-		}
+	handshakeCtx, cancelHandshake := context.WithTimeout(s.ctx,
+		s.config.handshakeTimeout())
+	defer cancelHandshake()
+	if err := conn.HandshakeContext(handshakeCtx); err != nil {
+		log.Error().Err(err).Msg("error handshaking")
+		return
 	}
 
 	clientCerts := conn.ConnectionState().PeerCertificates
@@ -117,39 +275,244 @@ func (s *Server) handleConnection(conn *tls.Conn) {
 		return
 	}
 
-	data, err := io.ReadAll(conn)
+	host := remoteHost(conn.RemoteAddr())
+
+	if s.peerStatus != nil {
+		s.peerStatus(nodeID, true)
+	}
+
+	ctx, cancel := context.WithCancel(withPeerCert(s.ctx, clientCerts[0]))
+	defer cancel()
+
+	if conn.ConnectionState().NegotiatedProtocol == alpnMux {
+		s.serveMux(ctx, conn, nodeID, host)
+		return
+	}
+
+	err = conn.SetReadDeadline(time.Now().Add(s.config.readTimeout()))
 	if err != nil {
+		log.Error().Err(err).Msg("error setting read deadline")
+		return
+	}
+
+	var (
+		response *Response
+		version  uint32 = ProtocolVersion
+	)
+	data, err := readMessage(conn, s.config.MaxMessageSize)
+	switch {
+	case errors.Is(err, errMessageTooLarge):
+		log.Warn().Msgf("message from %s is too large",
+			conn.RemoteAddr())
+		response = errorResponse(ErrBadRequest)
+	case err != nil && !s.isShuttingDown():
 		log.Error().Err(err).Msg("error reading connection")
 		return
+	case err != nil || s.isShuttingDown():
+		// Reply instead of closing the connection silently so that
+		// the client retries the request later.
+		response = errorResponse(ErrUnavailable)
+	default:
+		response, version = s.dispatch(ctx, nodeID, host, data)
+	}
+
+	responseBytes, err := encodeResponse(response, version)
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot encode response %v", response)
+		return
+	}
+
+	err = conn.SetWriteDeadline(time.Now().Add(s.config.writeTimeout()))
+	if err != nil {
+		log.Error().Err(err).Msg("error setting write deadline")
+		return
+	}
+	if _, err := conn.Write(responseBytes); err != nil {
+		log.Error().Err(err).Msgf("cannot write response to %s",
+			conn.RemoteAddr())
+		return
+	}
+}
+
+// serveMux reads request frames from a multiplexed connection and handles
+// them concurrently. Each response is written in a frame with the same
+// request ID as its request. It returns after the client closes the
+// connection or stays idle longer than the idle timeout, and all in-flight
+// requests are completed.
+func (s *Server) serveMux(ctx context.Context, conn *tls.Conn, nodeID string,
+	host string) {
+
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+	)
+	defer wg.Wait()
+
+	// Cancel in-flight requests once the connection cannot be read
+	// anymore since their responses cannot be delivered reliably.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, maxConcurrentRequests)
+
+	for {
+		err := conn.SetReadDeadline(
+			time.Now().Add(s.config.idleTimeout()))
+		if err != nil {
+			log.Error().Err(err).Msg("error setting read deadline")
+			return
+		}
+		// Check after extending the deadline so that the deadline set
+		// by Shutdown is not missed.
+		if s.isShuttingDown() {
+			return
+		}
+
+		requestID, data, err := readFrame(conn, s.config.MaxMessageSize)
+		if errors.Is(err, io.EOF) || s.isShuttingDown() {
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("error reading frame from %s",
+				conn.RemoteAddr())
+			return
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			s.handleFrame(ctx, conn, &writeMu, nodeID, host,
+				requestID, data)
+		}()
+	}
+}
+
+// handleFrame handles a request frame on a multiplexed connection and writes
+// the response frame. Writes are serialized by writeMu so that frames of
+// concurrent responses do not interleave.
+func (s *Server) handleFrame(ctx context.Context, conn *tls.Conn,
+	writeMu *sync.Mutex, nodeID string, host string, requestID uint32,
+	data []byte) {
+
+	response, _ := s.dispatch(ctx, nodeID, host, data)
+
+	responseBytes, err := proto.Marshal(response)
+	if err != nil {
+		log.Error().Err(err).Msgf("cannot encode response %v", response)
+		return
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	err = conn.SetWriteDeadline(time.Now().Add(s.config.writeTimeout()))
+	if err != nil {
+		log.Error().Err(err).Msg("error setting write deadline")
+		return
+	}
+	if err := writeFrame(conn, requestID, responseBytes,
+		s.config.MaxMessageSize); err != nil {
+
+		log.Error().Err(err).Msgf("cannot write response to %s",
+			conn.RemoteAddr())
 	}
+}
+
+// dispatch checks whether the request from the node ID is allowed, routes the
+// request to the handler of its event and wraps the result in the response
+// envelope. Errors returned by the handler are converted to error codes in the
+// envelope. Errors other than ResponseError are reported as internal errors
+// without revealing the details to the client. It also returns the protocol
+// version in the header of the request so that the response can be encoded for
+// the client.
+func (s *Server) dispatch(ctx context.Context, nodeID string, host string,
+	data []byte) (*Response, uint32) {
 
 	header, bodyBytes, err := extractMessage(data)
+	version := uint32(ProtocolVersion)
+	if err == nil {
+		version = header.GetVersion()
+	}
+
+	if s.guard != nil {
+		allowed, err := s.guard(nodeID, host)
+		if err != nil {
+			log.Error().Err(err).Msgf("error checking request "+
+				"from %s (%s)", host, nodeID)
+			return errorResponse(ErrInternal), version
+		}
+		if !allowed {
+			log.Info().Msgf("rejected request from %s (%s)", host,
+				nodeID)
+			return errorResponse(ErrBlocked), version
+		}
+	}
+
+	if !s.rateLimiter.allow(nodeID) {
+		log.Info().Msgf("rate limited request from %s (%s)", host,
+			nodeID)
+		return errorResponse(ErrRateLimited), version
+	}
+
 	if err != nil {
 		log.Warn().Err(err).Msg("unable to extract message")
-		return
+		return errorResponse(ErrBadRequest), version
 	}
 
 	handler, ok := s.handlerMap[header.GetEvent()]
 	if !ok {
 		log.Debug().Msgf("no such route %s", header.GetEvent())
-		return
+		return errorResponse(ErrUnknownEvent), version
 	}
 
-	response, err := handler(nodeID, bodyBytes)
+	response, err := handler(ctx, nodeID, bodyBytes)
 	if err != nil {
-		log.Warn().Err(err).Msg("handler error")
-		return
+		log.Warn().Err(err).Msgf("handler error %s", header.GetEvent())
+
+		var respErr *ResponseError
+		if errors.As(err, &respErr) {
+			return errorResponse(respErr), version
+		}
+		return errorResponse(ErrInternal), version
 	}
 
 	responseBytes, err := proto.Marshal(response)
 	if err != nil {
 		log.Error().Err(err).Msgf("cannot encode response %v", response)
-		return
+		return errorResponse(ErrInternal), version
 	}
-	if _, err := conn.Write(responseBytes); err != nil {
-		log.Error().Err(err).Msgf("cannot encode response %v", response)
-		return
+	if len(responseBytes) > s.config.MaxMessageSize {
+		log.Error().Msgf("response to %s is too large",
+			header.GetEvent())
+		return errorResponse(ErrInternal), version
+	}
+
+	return &Response{
+		Status: Status_STATUS_OK,
+		Body:   responseBytes,
+	}, version
+}
+
+func errorResponse(respErr *ResponseError) *Response {
+	return &Response{
+		Status:    Status_STATUS_ERROR,
+		ErrorCode: respErr.Code,
+		Message:   respErr.Message,
+	}
+}
+
+// remoteHost returns the host part of the remote address without port.
+func remoteHost(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
 	}
+	return host
 }
 
 // extractMessage extracts request body to the protocol format
@@ -0,0 +1,36 @@
+package webhook
+
+// Config defines the configuration options for posting events to webhooks.
+type Config struct {
+	// Timeout is the maximum duration (in seconds) of each delivery
+	// attempt.
+	Timeout int
+
+	// RetryInterval is the duration (in seconds) to wait before retrying
+	// the first failed delivery. The interval is doubled after each
+	// subsequent failure.
+	RetryInterval int
+
+	// MaxRetryInterval is the maximum duration (in seconds) between two
+	// delivery attempts.
+	MaxRetryInterval int
+
+	// MaxAttempts is the number of attempts after which an undelivered
+	// event is given up.
+	MaxAttempts int
+
+	// LogRetention is the duration (in seconds) for which finished
+	// deliveries are kept in the delivery log.
+	LogRetention int
+}
+
+// DefaultConfig returns all default values for the Config struct.
+func DefaultConfig() Config {
+	return Config{
+		Timeout:          10,
+		RetryInterval:    30,
+		MaxRetryInterval: 3600,
+		MaxAttempts:      10,
+		LogRetention:     604800,
+	}
+}
@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/event"
+)
+
+var (
+	// ErrInvalidURL is returned when registering a webhook with a URL that
+	// is not an absolute HTTP or HTTPS URL.
+	ErrInvalidURL = errors.New("invalid webhook url")
+
+	// ErrNoEvent is returned when registering a webhook without events.
+	ErrNoEvent = errors.New("webhook has no event")
+
+	// ErrInvalidEvent is returned when registering a webhook with an
+	// unknown event type.
+	ErrInvalidEvent = errors.New("invalid event")
+
+	// ErrWebhookNotFound is returned when there is no webhook with the
+	// specified ID.
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+// Manager contains a set of functionalities managing webhooks and posting
+// events to them. Events are recorded as deliveries in the database before
+// they are posted, so that failed deliveries can be retried with exponential
+// backoff and the user can inspect the delivery log.
+type Manager struct {
+	config     Config
+	db         *db.DB
+	httpClient *http.Client
+
+	// wakeCh notifies the worker that a new delivery is created.
+	wakeCh chan struct{}
+}
+
+// NewManager is a constructor of Manager. It records the events published on
+// the bus from now on, including the events published before Run.
+func NewManager(config Config, db *db.DB, events *event.Bus) *Manager {
+	m := &Manager{
+		config: config,
+		db:     db,
+		httpClient: &http.Client{
+			Timeout: time.Duration(config.Timeout) * time.Second,
+			// Redirects are not followed since they turn POST
+			// requests into GET requests.
+			CheckRedirect: noRedirect,
+		},
+		wakeCh: make(chan struct{}, 1),
+	}
+	events.AddHook(m.record)
+	return m
+}
+
+// CreateWebhook registers a webhook that receives events of the specified
+// types. A random secret for verifying the payloads is generated.
+func (m *Manager) CreateWebhook(rawURL string, events []event.Type) (
+	*db.Webhook, error) {
+
+	if !validURL(rawURL) {
+		return nil, ErrInvalidURL
+	}
+	if len(events) == 0 {
+		return nil, ErrNoEvent
+	}
+
+	eventStrings := make([]string, 0, len(events))
+	for _, e := range events {
+		if !validEvent(e) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidEvent, e)
+		}
+		eventStrings = append(eventStrings, string(e))
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &db.Webhook{
+		URL:    rawURL,
+		Secret: secret,
+		Events: strings.Join(eventStrings, " "),
+	}
+	if err := m.db.CreateWebhook(webhook); err != nil {
+		return nil, fmt.Errorf("create webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// ListWebhooks returns all webhooks.
+func (m *Manager) ListWebhooks() ([]db.Webhook, error) {
+	webhooks, err := m.db.ListWebhooks()
+	if err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// GetWebhook returns a webhook with the specified ID.
+func (m *Manager) GetWebhook(id uint) (*db.Webhook, error) {
+	webhook, err := m.db.FindWebhook(id)
+	if err != nil {
+		return nil, fmt.Errorf("find webhook %d: %w", id, err)
+	}
+	if webhook == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook deletes a webhook with the specified ID together with its
+// delivery log. Pending deliveries are discarded.
+func (m *Manager) DeleteWebhook(id uint) error {
+	ok, err := m.db.DeleteWebhook(id)
+	if err != nil {
+		return fmt.Errorf("delete webhook %d: %w", id, err)
+	}
+	if !ok {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListDeliveries returns deliveries of the webhook with the specified ID,
+// newest first, together with the total number of its deliveries.
+func (m *Manager) ListDeliveries(webhookID uint, offset int, limit int) (
+	[]db.WebhookDelivery, int64, error) {
+
+	if _, err := m.GetWebhook(webhookID); err != nil {
+		return nil, 0, err
+	}
+
+	deliveries, total, err := m.db.ListWebhookDeliveries(webhookID, offset,
+		limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list webhook deliveries %d: %w",
+			webhookID, err)
+	}
+	return deliveries, total, nil
+}
+
+// Events returns the event types posted to a webhook.
+func Events(webhook *db.Webhook) []event.Type {
+	fields := strings.Fields(webhook.Events)
+	events := make([]event.Type, 0, len(fields))
+	for _, field := range fields {
+		events = append(events, event.Type(field))
+	}
+	return events
+}
+
+func noRedirect(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+func validURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func validEvent(e event.Type) bool {
+	for _, t := range event.Types {
+		if t == e {
+			return true
+		}
+	}
+	return false
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand read: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
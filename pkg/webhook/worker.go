@@ -0,0 +1,279 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sunboyy/lettered/pkg/backoff"
+	"github.com/sunboyy/lettered/pkg/db"
+	"github.com/sunboyy/lettered/pkg/event"
+)
+
+const (
+	// pollInterval is the duration between two checks for due deliveries.
+	pollInterval = 10 * time.Second
+
+	// pruneInterval is the duration between two removals of expired
+	// deliveries from the delivery log.
+	pruneInterval = time.Hour
+
+	// batchSize is the maximum number of deliveries processed in each
+	// check.
+	batchSize = 50
+
+	// maxResponseSize is the maximum number of bytes read from the
+	// response body of the receiver. The body is discarded.
+	maxResponseSize = 64 << 10
+)
+
+// Headers of the requests posted to webhooks.
+const (
+	// HeaderEvent is the type of the event.
+	HeaderEvent = "X-Lettered-Event"
+
+	// HeaderDelivery is the ID of the delivery. It stays the same across
+	// retries so that the receiver can discard duplicates.
+	HeaderDelivery = "X-Lettered-Delivery"
+
+	// HeaderTimestamp is the Unix time at which the request is signed.
+	HeaderTimestamp = "X-Lettered-Timestamp"
+
+	// HeaderSignature is "sha256=" followed by the hex-encoded HMAC-SHA256
+	// of the timestamp, a '.' and the request body, keyed with the secret
+	// of the webhook.
+	HeaderSignature = "X-Lettered-Signature"
+)
+
+// errUnexpectedStatus is an error indicating that the receiver responds with
+// a non-2xx status code.
+var errUnexpectedStatus = errors.New("unexpected status")
+
+// Run posts the recorded deliveries. It blocks until the context is done.
+// Deliveries interrupted by the context are retried on the next run.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	pruneTicker := time.NewTicker(pruneInterval)
+	defer pruneTicker.Stop()
+
+	m.prune()
+	for {
+		m.processDueDeliveries(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-pruneTicker.C:
+			m.prune()
+		case <-ticker.C:
+		case <-m.wakeCh:
+		}
+	}
+}
+
+// record creates a delivery of the published event for each webhook that
+// subscribes to the event type. It is called by the publisher of the event.
+func (m *Manager) record(e event.Event) {
+	webhooks, err := m.db.ListWebhooks()
+	if err != nil {
+		log.Error().Err(err).Msg("webhook: error listing webhooks")
+		return
+	}
+
+	var payload []byte
+	for i := range webhooks {
+		if !subscribes(&webhooks[i], e.Type) {
+			continue
+		}
+
+		if payload == nil {
+			payload, err = json.Marshal(e)
+			if err != nil {
+				log.Error().Err(err).Msgf(
+					"webhook: error encoding event %d",
+					e.ID)
+				return
+			}
+		}
+
+		if err := m.db.CreateWebhookDelivery(&db.WebhookDelivery{
+			WebhookID:     webhooks[i].ID,
+			Event:         string(e.Type),
+			Payload:       payload,
+			Status:        db.WebhookDeliveryPending,
+			NextAttemptAt: time.Now(),
+		}); err != nil {
+			log.Error().Err(err).Msgf(
+				"webhook: error creating delivery to %d",
+				webhooks[i].ID)
+		}
+	}
+
+	if payload != nil {
+		select {
+		case m.wakeCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (m *Manager) processDueDeliveries(ctx context.Context) {
+	deliveries, err := m.db.ListDueWebhookDeliveries(time.Now(), batchSize)
+	if err != nil {
+		log.Error().Err(err).
+			Msg("webhook: error listing due deliveries")
+		return
+	}
+
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+		m.process(ctx, &deliveries[i])
+	}
+}
+
+// process attempts to post a delivery. The delivery is finished after the
+// receiver accepts it, after it is rejected with a status code that is not
+// worth retrying or after the maximum number of attempts. Otherwise, the next
+// attempt is scheduled.
+func (m *Manager) process(ctx context.Context, delivery *db.WebhookDelivery) {
+	webhook, err := m.db.FindWebhook(delivery.WebhookID)
+	if err != nil {
+		log.Error().Err(err).Msgf("webhook: error finding webhook %d",
+			delivery.WebhookID)
+		return
+	}
+	if webhook == nil {
+		// The webhook has been deleted concurrently.
+		return
+	}
+
+	statusCode, err := m.post(ctx, webhook, delivery)
+
+	// The delivery is interrupted by shutting down. It is not counted as
+	// an attempt.
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	delivery.Attempts++
+	delivery.ResponseStatus = statusCode
+	switch {
+	case err == nil:
+		delivery.Status = db.WebhookDeliveryDelivered
+		delivery.LastError = ""
+	case !retryable(statusCode) ||
+		delivery.Attempts >= m.config.MaxAttempts:
+
+		log.Warn().Err(err).Msgf("webhook: giving up delivery %d to "+
+			"%s after %d attempts", delivery.ID, webhook.URL,
+			delivery.Attempts)
+		delivery.Status = db.WebhookDeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		log.Debug().Err(err).Msgf("webhook: failed delivery %d to %s "+
+			"(attempt %d)", delivery.ID, webhook.URL,
+			delivery.Attempts)
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = time.Now().Add(backoff.Exponential(
+			time.Duration(m.config.RetryInterval)*time.Second,
+			time.Duration(m.config.MaxRetryInterval)*time.Second,
+			delivery.Attempts))
+	}
+
+	if err := m.db.UpdateWebhookDelivery(delivery); err != nil {
+		log.Error().Err(err).Msg("webhook: error updating delivery")
+	}
+}
+
+// post sends the payload of the delivery to the webhook with the signature
+// headers. It returns the status code of the response, or zero if there is
+// no response.
+func (m *Manager) post(ctx context.Context, webhook *db.Webhook,
+	delivery *db.WebhookDelivery) (int, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("new request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lettered-webhook")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(
+		uint64(delivery.ID), 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+
+		Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	res, err := m.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("post: %w", err)
+	}
+	defer res.Body.Close()
+
+	// Drain the body so that the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseSize))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("%w %d", errUnexpectedStatus,
+			res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// prune removes finished deliveries that are older than the log retention
+// from the delivery log.
+func (m *Manager) prune() {
+	before := time.Now().Add(
+		-time.Duration(m.config.LogRetention) * time.Second)
+	if err := m.db.DeleteFinishedWebhookDeliveries(before); err != nil {
+		log.Error().Err(err).Msg("webhook: error pruning deliveries")
+	}
+}
+
+// Sign returns the hex-encoded signature of the payload signed at the
+// timestamp with the secret of a webhook. Receivers compute the same value
+// to verify the HeaderSignature header.
+func Sign(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// subscribes reports whether the webhook receives events of the type.
+func subscribes(webhook *db.Webhook, eventType event.Type) bool {
+	for _, t := range Events(webhook) {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// retryable reports whether a delivery that fails with the status code is
+// worth retrying. Failures without a response, server errors, timeouts and
+// rate limiting are retried.
+func retryable(statusCode int) bool {
+	return statusCode == 0 ||
+		statusCode >= 500 ||
+		statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests
+}